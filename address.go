@@ -1,11 +1,19 @@
 package bip352
 
 import (
+	"errors"
+
 	"github.com/btcsuite/btcd/btcutil/bech32"
 	"github.com/setavenger/blindbit-lib/utils"
 )
 
-func CreateAddress(scanPubKeyBytes, bMKeyBytes *[33]byte, mainnet bool, version uint8) (string, error) {
+// CreateAddress encodes the scan and spend public key into a silent payment address.
+// Versions 1 to 30 only encode the v0 payload which is what readers of those versions use.
+func CreateAddress(scanPubKeyBytes, bMKeyBytes *[33]byte, network Network, version uint8) (string, error) {
+	if err := checkAddressVersion(version); err != nil {
+		return "", err
+	}
+
 	var data []byte
 	//data = append(data, version)
 	data = append(data, scanPubKeyBytes[:]...)
@@ -22,16 +30,12 @@ func CreateAddress(scanPubKeyBytes, bMKeyBytes *[33]byte, mainnet bool, version
 	finalSlice = append(finalSlice, version)
 	finalSlice = append(finalSlice, convertBits...)
 
-	if mainnet {
-		return bech32.EncodeM("sp", finalSlice)
-	} else {
-		return bech32.EncodeM("tsp", finalSlice)
-	}
+	return bech32.EncodeM(network.HRP(), finalSlice)
 }
 
 func CreateLabeledAddress(
 	scanPubKeyBytes, spendPubKeyBytes *[33]byte,
-	mainnet bool,
+	network Network,
 	version uint8,
 	scanSecKey *[32]byte,
 	m uint32,
@@ -49,16 +53,33 @@ func CreateLabeledAddress(
 		return "", err
 	}
 
-	return CreateAddress(scanPubKeyBytes, &bMKeyBytes, mainnet, version)
+	return CreateAddress(scanPubKeyBytes, &bMKeyBytes, network, version)
 }
 
 // DecodeSilentPaymentAddress returns the components of an SP address
+// The data of addresses with versions 1 to 30 can be longer than 66 bytes,
+// according to BIP352 only the first 66 bytes are relevant for those versions.
 // Returns:
 // 1. hrp
 // 2. the raw byte data that was encoded
 // 3. the version
 // 4. the error, if one occurs
-func DecodeSilentPaymentAddress(address string, mainnet bool) (string, []byte, uint8, error) {
+func DecodeSilentPaymentAddress(address string, network Network) (string, []byte, uint8, error) {
+	hrp, data, version, err := decodeAddress(address)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	// check that we have the correct hrp
+	if hrp != network.HRP() {
+		return "", nil, 0, AddressHRPError
+	}
+
+	return hrp, data, version, nil
+}
+
+// decodeAddress decodes an address without checking the hrp against a network
+func decodeAddress(address string) (string, []byte, uint8, error) {
 	// check according to recommended length in BIP. underlying library does not do the check, so we do it here
 	if len(address) > 1023 {
 		return "", nil, 0, DecodingLimitExceeded
@@ -67,38 +88,66 @@ func DecodeSilentPaymentAddress(address string, mainnet bool) (string, []byte, u
 	if err != nil {
 		return "", nil, 0, err
 	}
-
-	// check that we have the correct hrp
-	if hrp == "sp" && !mainnet {
-		return "", nil, 0, AddressHRPError
-	}
-	if hrp == "tsp" && mainnet {
-		return "", nil, 0, AddressHRPError
+	if len(data) == 0 {
+		return "", nil, 0, ErrInvalidAddressLength
 	}
 
 	// extract everything but the version as data
 	version, data := data[0], data[1:]
 
+	if err = checkAddressVersion(version); err != nil {
+		return "", nil, 0, err
+	}
+
 	data, err = bech32.ConvertBits(data, 5, 8, false)
 	if err != nil {
 		return "", nil, 0, err
 	}
+
+	// v0 has to be exactly 66 bytes, future versions are forward compatible and can append data
+	if (version == 0 && len(data) != 66) || len(data) < 66 {
+		return "", nil, 0, ErrInvalidAddressLength
+	}
+
 	return hrp, data, version, nil
 }
 
+// checkAddressVersion checks whether a version can be read by a v0 implementation.
+// Version 31 is reserved for a backwards incompatible change.
+func checkAddressVersion(version uint8) error {
+	switch {
+	case version > 31:
+		return ErrAddressVersionInvalid
+	case version == 31:
+		return ErrAddressVersionIncompatible
+	default:
+		return nil
+	}
+}
+
 func DecodeSilentPaymentAddressToKeys(
 	address string,
-	mainnet bool,
+	network Network,
 ) (
 	scanPubKeyBytes, spendPubKeyBytes [33]byte,
 	err error,
 ) {
-	_, data, _, err := DecodeSilentPaymentAddress(address, mainnet)
+	_, data, _, err := DecodeSilentPaymentAddress(address, network)
 	if err != nil {
 		return [33]byte{}, [33]byte{}, err
 	}
 
-	return utils.ConvertToFixedLength33(data[:33]), utils.ConvertToFixedLength33(data[33:]), err
+	return splitAddressData(data)
+}
+
+// splitAddressData returns the scan and spend public key of the decoded address payload.
+// Additional data of future versions is ignored.
+func splitAddressData(data []byte) (scanPubKeyBytes, spendPubKeyBytes [33]byte, err error) {
+	if len(data) < 66 {
+		return [33]byte{}, [33]byte{}, ErrInvalidAddressLength
+	}
+
+	return utils.ConvertToFixedLength33(data[:33]), utils.ConvertToFixedLength33(data[33:66]), nil
 }
 
 // CreateLabelledSpendPubKey Returns the labeled spend pub key
@@ -109,14 +158,12 @@ func CreateLabelledSpendPubKey(spendPubKey, labelPubKey *[33]byte) ([33]byte, er
 }
 
 // IsSilentPaymentAddress determines whether an address is a silent payment address.
-// Works only for silent payment v0
+// Addresses of future versions are accepted as long as a v0 implementation can read them.
 func IsSilentPaymentAddress(address string) bool {
-	// only works for v1
-	if len(address) == 116 && address[:2] == "sp" {
-		return true
-	}
-	if len(address) == 117 && address[:3] == "tsp" {
-		return true
+	hrp, _, _, err := decodeAddress(address)
+	if err != nil {
+		return false
 	}
-	return false
+	_, err = NetworkFromHRP(hrp)
+	return err == nil || errors.Is(err, ErrNetworkAmbiguous)
 }
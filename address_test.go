@@ -5,11 +5,12 @@ import (
 	"encoding/hex"
 	"errors"
 	"math/rand"
+	"strings"
 	"testing"
 
 	"github.com/btcsuite/btcd/btcutil/bech32"
 	"github.com/setavenger/blindbit-lib/utils"
-	golibsecp256k1 "github.com/setavenger/go-libsecp256k1"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFullAddressEncoding(t *testing.T) {
@@ -40,11 +41,11 @@ func TestFullAddressEncoding(t *testing.T) {
 			scanSecKeyBytes := utils.ConvertToFixedLength32(scanSecKey)
 			spendSecKeyBytes := utils.ConvertToFixedLength32(spendSecKey)
 
-			scanPubKeyBytes := golibsecp256k1.PubKeyFromSecKey(&scanSecKeyBytes)
-			spendPubKeyBytes := golibsecp256k1.PubKeyFromSecKey(&spendSecKeyBytes)
+			scanPubKeyBytes := PubKeyFromSecKey(&scanSecKeyBytes)
+			spendPubKeyBytes := PubKeyFromSecKey(&spendSecKeyBytes)
 
 			var address = ""
-			address, err = CreateAddress(scanPubKeyBytes, spendPubKeyBytes, true, 0)
+			address, err = CreateAddress(scanPubKeyBytes, spendPubKeyBytes, Mainnet, 0)
 			if err != nil {
 				t.Errorf("Error: %s", err)
 				return
@@ -57,7 +58,7 @@ func TestFullAddressEncoding(t *testing.T) {
 				labeledAddress, err = CreateLabeledAddress(
 					scanPubKeyBytes,
 					spendPubKeyBytes,
-					true,
+					Mainnet,
 					0,
 					&scanSecKeyBytes,
 					label,
@@ -83,12 +84,12 @@ func TestFullAddressEncoding(t *testing.T) {
 func TestDecodeSPAddress(t *testing.T) {
 	decodedData, _ := hex.DecodeString("0220bcfac5b99e04ad1a06ddfb016ee13582609d60b6291e98d01a9bc9a16c96d4025cc9856d6f8375350e123978daac200c260cb5b5ae83106cab90484dcd8fcf36")
 	address := "sp1qqgste7k9hx0qftg6qmwlkqtwuy6cycyavzmzj85c6qdfhjdpdjtdgqjuexzk6murw56suy3e0rd2cgqvycxttddwsvgxe2usfpxumr70xc9pkqwv"
-	_, _, _, err := DecodeSilentPaymentAddress(address, false)
+	_, _, _, err := DecodeSilentPaymentAddress(address, Testnet)
 	if !errors.Is(err, AddressHRPError) {
 		t.Errorf("Error: wrong error %s", err)
 		return
 	}
-	hrp, data, version, err := DecodeSilentPaymentAddress(address, true)
+	hrp, data, version, err := DecodeSilentPaymentAddress(address, Mainnet)
 	if err != nil {
 		t.Errorf("Error: %s", err)
 		return
@@ -113,7 +114,7 @@ func TestDecodeSPAddressToKeys(t *testing.T) {
 	scanPubKeyBytesCheck, _ := hex.DecodeString("0220bcfac5b99e04ad1a06ddfb016ee13582609d60b6291e98d01a9bc9a16c96d4")
 	spendPubKeyBytesCheck, _ := hex.DecodeString("025cc9856d6f8375350e123978daac200c260cb5b5ae83106cab90484dcd8fcf36")
 	address := "sp1qqgste7k9hx0qftg6qmwlkqtwuy6cycyavzmzj85c6qdfhjdpdjtdgqjuexzk6murw56suy3e0rd2cgqvycxttddwsvgxe2usfpxumr70xc9pkqwv"
-	scanPubKeyBytes, spendPubKeyBytes, err := DecodeSilentPaymentAddressToKeys(address, true)
+	scanPubKeyBytes, spendPubKeyBytes, err := DecodeSilentPaymentAddressToKeys(address, Mainnet)
 	if err != nil {
 		t.Errorf("Error: %s", err)
 		return
@@ -145,9 +146,144 @@ func TestBech32MDecodingLimitExceeded_Error(t *testing.T) {
 		return
 	}
 
-	_, _, _, err = DecodeSilentPaymentAddress(encoded, true)
+	_, _, _, err = DecodeSilentPaymentAddress(encoded, Mainnet)
 	if !errors.Is(err, DecodingLimitExceeded) {
 		t.Errorf("Error: wrong error %s", err)
 		return
 	}
 }
+
+func TestDecodeSPAddressVersions(t *testing.T) {
+	payload, _ := hex.DecodeString("0220bcfac5b99e04ad1a06ddfb016ee13582609d60b6291e98d01a9bc9a16c96d4025cc9856d6f8375350e123978daac200c260cb5b5ae83106cab90484dcd8fcf36")
+	extendedPayload := append(append([]byte{}, payload...), 0xde, 0xad, 0xbe, 0xef)
+
+	testCases := []struct {
+		name    string
+		version uint8
+		payload []byte
+		err     error
+	}{
+		{name: "v0", version: 0, payload: payload},
+		{name: "v0 with additional data", version: 0, payload: extendedPayload, err: ErrInvalidAddressLength},
+		{name: "v0 too short", version: 0, payload: payload[:65], err: ErrInvalidAddressLength},
+		{name: "v1", version: 1, payload: payload},
+		{name: "v1 with additional data", version: 1, payload: extendedPayload},
+		{name: "v1 too short", version: 1, payload: payload[:65], err: ErrInvalidAddressLength},
+		{name: "v30 with additional data", version: 30, payload: extendedPayload},
+		{name: "v31", version: 31, payload: payload, err: ErrAddressVersionIncompatible},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			address := encodeTestAddress(t, "sp", testCase.version, testCase.payload)
+
+			_, data, version, err := DecodeSilentPaymentAddress(address, Mainnet)
+			if testCase.err != nil {
+				if !errors.Is(err, testCase.err) {
+					t.Errorf("Error: wrong error %v != %v", err, testCase.err)
+				}
+				if IsSilentPaymentAddress(address) {
+					t.Errorf("Error: %s should not be a valid address", address)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Error: %s", err)
+				return
+			}
+			if version != testCase.version {
+				t.Errorf("Error: wrong version %d != %d", version, testCase.version)
+			}
+			if !bytes.Equal(data, testCase.payload) {
+				t.Errorf("Error: data not decoded correctly")
+			}
+			if !IsSilentPaymentAddress(address) {
+				t.Errorf("Error: %s should be a valid address", address)
+			}
+
+			// only the first 66 bytes are used for the keys
+			parsed, err := ParseAddress(address)
+			if err != nil {
+				t.Errorf("Error: %s", err)
+				return
+			}
+			scanPubKey, spendPubKey := parsed.ScanKey(), parsed.SpendKey()
+			if !bytes.Equal(payload, append(scanPubKey[:], spendPubKey[:]...)) {
+				t.Errorf("Error: wrong keys extracted")
+			}
+		})
+	}
+}
+
+func TestCreateAddressVersions(t *testing.T) {
+	scanPubKey := utils.ConvertToFixedLength33(make([]byte, 33))
+	spendPubKey := utils.ConvertToFixedLength33(make([]byte, 33))
+
+	_, err := CreateAddress(&scanPubKey, &spendPubKey, Mainnet, 31)
+	if !errors.Is(err, ErrAddressVersionIncompatible) {
+		t.Errorf("Error: wrong error %v", err)
+	}
+	_, err = CreateAddress(&scanPubKey, &spendPubKey, Mainnet, 32)
+	if !errors.Is(err, ErrAddressVersionInvalid) {
+		t.Errorf("Error: wrong error %v", err)
+	}
+}
+
+func FuzzDecodeSilentPaymentAddress(f *testing.F) {
+	caseData, err := LoadFullCaseData(f)
+	require.NoError(f, err)
+
+	for _, cases := range caseData {
+		for _, testCase := range cases.Sending {
+			for _, address := range testCase.Given.Recipients {
+				f.Add(address)
+			}
+		}
+		for _, testCase := range cases.Receiving {
+			for _, address := range testCase.Expected.Addresses {
+				f.Add(address)
+			}
+		}
+	}
+	f.Add(testAddress)
+	f.Add(strings.ToUpper(testAddress))
+
+	f.Fuzz(func(t *testing.T, address string) {
+		hrp, data, version, err := decodeAddress(address)
+		if err != nil {
+			return
+		}
+
+		network, err := NetworkFromHRP(hrp)
+		if errors.Is(err, ErrNetworkAmbiguous) {
+			network = Testnet
+		} else if err != nil {
+			return
+		}
+
+		_, _, _, err = DecodeSilentPaymentAddress(address, network)
+		require.NoError(t, err)
+
+		scanPubKey, spendPubKey, err := splitAddressData(data)
+		require.NoError(t, err)
+
+		// v0 addresses carry no additional data, decode(encode(x)) == x
+		if version == 0 {
+			encoded, err := CreateAddress(&scanPubKey, &spendPubKey, network, version)
+			require.NoError(t, err)
+			require.Equal(t, strings.ToLower(address), encoded)
+		}
+
+		// for all versions the keys survive a roundtrip
+		parsed, err := ParseAddressForNetwork(address, network)
+		if err != nil {
+			require.ErrorIs(t, err, ErrInvalidPublicKey)
+			return
+		}
+		reparsed, err := ParseAddressForNetwork(parsed.String(), network)
+		require.NoError(t, err)
+		require.Equal(t, parsed, reparsed)
+		require.Equal(t, scanPubKey, reparsed.ScanKey())
+		require.Equal(t, spendPubKey, reparsed.SpendKey())
+	})
+}
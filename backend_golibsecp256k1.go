@@ -0,0 +1,56 @@
+//go:build !purego
+
+package bip352
+
+import (
+	"fmt"
+
+	golibsecp256k1 "github.com/setavenger/go-libsecp256k1"
+)
+
+// Curve operations backed by go-libsecp256k1.
+// Build with the libsecp256k1 tag to use the cgo bindings of libsecp256k1, see backend_purego.go for the alternative.
+
+func secKeyAdd(secKey, tweak *[32]byte) error {
+	if err := golibsecp256k1.SecKeyAdd(secKey, tweak); err != nil {
+		return fmt.Errorf("%w: %v", ErrTweak, err)
+	}
+	return nil
+}
+
+func secKeyMul(secKey, tweak *[32]byte) error {
+	if err := golibsecp256k1.MultPrivateKeys(secKey, tweak); err != nil {
+		return fmt.Errorf("%w: %v", ErrTweak, err)
+	}
+	return nil
+}
+
+func pubKeyAdd(pubKey1, pubKey2 *[33]byte) ([33]byte, error) {
+	result, err := golibsecp256k1.PubKeyAdd(pubKey1, pubKey2)
+	if err != nil {
+		return [33]byte{}, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+	return result, nil
+}
+
+func pubKeyTweakMul(pubKey *[33]byte, tweak *[32]byte) error {
+	// the library does not reject zero or overflowing tweaks in all builds
+	if _, err := parseTweak(tweak); err != nil {
+		return err
+	}
+	if err := golibsecp256k1.PubKeyTweakMul(pubKey, tweak); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+	return nil
+}
+
+func pubKeyNegate(pubKey *[33]byte) error {
+	if err := golibsecp256k1.PubKeyNegate(pubKey); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+	return nil
+}
+
+func pubKeyFromSecKey(secKey *[32]byte) *[33]byte {
+	return golibsecp256k1.PubKeyFromSecKey(secKey)
+}
@@ -0,0 +1,121 @@
+//go:build purego
+
+package bip352
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// Curve operations implemented with btcec/v2 in pure Go.
+// Selected with the purego build tag for builds without cgo, e.g. static binaries or WASM.
+
+func secKeyAdd(secKey, tweak *[32]byte) error {
+	key, err := parseScalar(secKey)
+	if err != nil {
+		return err
+	}
+	t, err := parseScalar(tweak)
+	if err != nil {
+		return err
+	}
+
+	key.Add(&t)
+	if key.IsZero() {
+		return fmt.Errorf("%w: result is zero", ErrTweak)
+	}
+
+	*secKey = key.Bytes()
+	return nil
+}
+
+func secKeyMul(secKey, tweak *[32]byte) error {
+	key, err := parseScalar(secKey)
+	if err != nil {
+		return err
+	}
+	t, err := parseScalar(tweak)
+	if err != nil {
+		return err
+	}
+
+	key.Mul(&t)
+	if key.IsZero() {
+		return fmt.Errorf("%w: result is zero", ErrTweak)
+	}
+
+	*secKey = key.Bytes()
+	return nil
+}
+
+func pubKeyAdd(pubKey1, pubKey2 *[33]byte) ([33]byte, error) {
+	p1, err := parsePoint(pubKey1)
+	if err != nil {
+		return [33]byte{}, err
+	}
+	p2, err := parsePoint(pubKey2)
+	if err != nil {
+		return [33]byte{}, err
+	}
+
+	var result btcec.JacobianPoint
+	btcec.AddNonConst(&p1, &p2, &result)
+
+	return serializePoint(&result)
+}
+
+func pubKeyTweakMul(pubKey *[33]byte, tweak *[32]byte) error {
+	t, err := parseTweak(tweak)
+	if err != nil {
+		return err
+	}
+	p, err := parsePoint(pubKey)
+	if err != nil {
+		return err
+	}
+
+	var result btcec.JacobianPoint
+	btcec.ScalarMultNonConst(&t, &p, &result)
+
+	*pubKey, err = serializePoint(&result)
+	return err
+}
+
+func pubKeyNegate(pubKey *[33]byte) error {
+	if _, err := parsePoint(pubKey); err != nil {
+		return err
+	}
+
+	// the y-coordinate of -P has the opposite parity
+	pubKey[0] ^= 0x01
+	return nil
+}
+
+func pubKeyFromSecKey(secKey *[32]byte) *[33]byte {
+	_, pubKey := btcec.PrivKeyFromBytes(secKey[:])
+	var result [33]byte
+	copy(result[:], pubKey.SerializeCompressed())
+	return &result
+}
+
+func parsePoint(pubKey *[33]byte) (btcec.JacobianPoint, error) {
+	var point btcec.JacobianPoint
+	p, err := btcec.ParsePubKey(pubKey[:])
+	if err != nil {
+		return point, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+	p.AsJacobian(&point)
+	return point, nil
+}
+
+func serializePoint(point *btcec.JacobianPoint) ([33]byte, error) {
+	if isInfinity(point) {
+		return [33]byte{}, fmt.Errorf("%w: point at infinity", ErrInvalidPublicKey)
+	}
+
+	point.ToAffine()
+	var result [33]byte
+	copy(result[:], btcec.NewPublicKey(&point.X, &point.Y).SerializeCompressed())
+	return result, nil
+}
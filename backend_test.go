@@ -0,0 +1,98 @@
+package bip352
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBackend checks the curve operations of the selected backend against btcec.
+// Run with -tags purego to test the pure Go backend.
+func TestBackend(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		a := sha256.Sum256([]byte(fmt.Sprintf("a%d", i)))
+		b := sha256.Sum256([]byte(fmt.Sprintf("b%d", i)))
+
+		var scalarA, scalarB btcec.ModNScalar
+		scalarA.SetBytes(&a)
+		scalarB.SetBytes(&b)
+
+		pubA := pubKeyFromSecKey(&a)
+		pubB := pubKeyFromSecKey(&b)
+		_, expectedPubA := btcec.PrivKeyFromBytes(a[:])
+		require.Equal(t, expectedPubA.SerializeCompressed(), pubA[:])
+
+		// a+b
+		sum := a
+		require.NoError(t, secKeyAdd(&sum, &b))
+		expectedSum := new(btcec.ModNScalar).Add2(&scalarA, &scalarB).Bytes()
+		require.Equal(t, expectedSum, sum)
+
+		// aG+bG = (a+b)G
+		pubSum, err := pubKeyAdd(pubA, pubB)
+		require.NoError(t, err)
+		require.Equal(t, *pubKeyFromSecKey(&sum), pubSum)
+
+		// a*b
+		product := a
+		require.NoError(t, secKeyMul(&product, &b))
+		expectedProduct := new(btcec.ModNScalar).Mul2(&scalarA, &scalarB).Bytes()
+		require.Equal(t, expectedProduct, product)
+
+		// b*(aG) = (a*b)G
+		tweaked := *pubA
+		require.NoError(t, pubKeyTweakMul(&tweaked, &b))
+		require.Equal(t, *pubKeyFromSecKey(&product), tweaked)
+
+		// -(aG) = (-a)G
+		negated := *pubA
+		require.NoError(t, pubKeyNegate(&negated))
+		negatedSecKey := new(btcec.ModNScalar).NegateVal(&scalarA).Bytes()
+		require.Equal(t, *pubKeyFromSecKey(&negatedSecKey), negated)
+
+		// aG + (-aG) is the point at infinity
+		_, err = pubKeyAdd(pubA, &negated)
+		require.Error(t, err)
+	}
+}
+
+func TestBackendInvalid(t *testing.T) {
+	orderBytes, _ := hex.DecodeString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
+	var order [32]byte
+	copy(order[:], orderBytes)
+
+	one := [32]byte{31: 1}
+	nMinusOne := new(btcec.ModNScalar).NegateVal(new(btcec.ModNScalar).SetInt(1)).Bytes()
+
+	// a + (n-a) = 0
+	secKey := one
+	require.ErrorIs(t, secKeyAdd(&secKey, &nMinusOne), ErrTweak)
+	require.Equal(t, one, secKey, "the key must not be modified on failure")
+
+	secKey = one
+	require.ErrorIs(t, secKeyMul(&secKey, &[32]byte{}), ErrTweak)
+
+	// the errors do not depend on the backend
+	pubKey := *pubKeyFromSecKey(&one)
+	require.ErrorIs(t, pubKeyTweakMul(&pubKey, &[32]byte{}), ErrTweak)
+	require.ErrorIs(t, pubKeyTweakMul(&pubKey, &order), ErrTweak)
+	require.Equal(t, *pubKeyFromSecKey(&one), pubKey, "the key must not be modified on failure")
+
+	invalidPoint := [33]byte{0x02}
+	require.ErrorIs(t, pubKeyNegate(&invalidPoint), ErrInvalidPublicKey)
+	_, err := pubKeyAdd(&invalidPoint, &pubKey)
+	require.ErrorIs(t, err, ErrInvalidPublicKey)
+	_, err = pubKeyAdd(&pubKey, &invalidPoint)
+	require.ErrorIs(t, err, ErrInvalidPublicKey)
+	require.ErrorIs(t, pubKeyTweakMul(&invalidPoint, &one), ErrInvalidPublicKey)
+
+	// P + (-P) is the point at infinity
+	negated := pubKey
+	require.NoError(t, pubKeyNegate(&negated))
+	_, err = pubKeyAdd(&pubKey, &negated)
+	require.ErrorIs(t, err, ErrInvalidPublicKey)
+}
@@ -0,0 +1,439 @@
+package bip352
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// changeDustLimit change below the dust limit of a P2TR output is added to the fee
+	changeDustLimit = 330
+
+	// weights of the parts of a transaction in weight units
+	txOverheadWeight = (4 + 1 + 1 + 4) * 4 // version, input and output count, lock time
+	segwitFlagWeight = 2                   // marker and flag
+	txInBaseWeight   = (32 + 4 + 1 + 4) * 4
+
+	p2trWitnessWeight         = 1 + 1 + 64            // key path spend with default sighash
+	p2wpkhWitnessWeight       = 1 + 1 + 73 + 1 + 33   // DER signature with sighash byte and public key
+	p2pkhScriptSigWeight      = (1 + 73 + 1 + 33) * 4 // DER signature with sighash byte and public key
+	p2shP2WPKHScriptSigWeight = (1 + 22) * 4          // push of the P2WPKH redeem script
+)
+
+// Payment is an output requested from a TxBuilder
+type Payment struct {
+	Address string // silent payment address or regular address
+	Amount  uint64 // value in satoshi
+}
+
+// TxBuilder builds unsigned transactions paying silent payment and regular addresses.
+// Change is paid to the change address of the sender, the address for label m=0.
+type TxBuilder struct {
+	changeKey     *WatchOnlyKey
+	changeAddress string
+	feeRate       uint64
+	outputOrder   OutputOrder
+}
+
+// UnsignedTx is a transaction built by a TxBuilder together with the data needed to sign it
+type UnsignedTx struct {
+	Tx      *wire.MsgTx
+	Vins    []*Vin        // the selected utxos in the order of Tx.TxIn, they hold the keys or signers to sign with
+	Outputs []*BuiltTxOut // information about the outputs in the order of Tx.TxOut
+	Fee     uint64        // fee in satoshi
+}
+
+// BuiltTxOut describes an output of an UnsignedTx
+type BuiltTxOut struct {
+	Address       string        // the address of the payment or the change address
+	Amount        uint64        // value in satoshi
+	PkScript      []byte        // the script of the output
+	SilentPayment *SenderOutput // set for outputs to silent payment addresses
+	Change        bool          // true for the change output
+}
+
+// NewTxBuilder creates a TxBuilder.
+// changeKey: the key of the sender, change is paid to its address for label m=0
+// feeRate: in sat/vB
+// outputOrder: the order of the outputs of the built transactions
+func NewTxBuilder(changeKey *WatchOnlyKey, feeRate uint64, outputOrder OutputOrder) (*TxBuilder, error) {
+	labelManager, err := changeKey.LabelManager()
+	if err != nil {
+		return nil, err
+	}
+	changeLabel, err := labelManager.ChangeLabel()
+	if err != nil {
+		return nil, err
+	}
+
+	return &TxBuilder{
+		changeKey:     changeKey,
+		changeAddress: changeLabel.Address,
+		feeRate:       feeRate,
+		outputOrder:   outputOrder,
+	}, nil
+}
+
+// ChangeAddress returns the address change is paid to
+func (b *TxBuilder) ChangeAddress() string {
+	return b.changeAddress
+}
+
+// builderUtxo is a utxo that can be selected as input
+type builderUtxo struct {
+	vin      *Vin
+	weight   int  // weight of the signed input
+	witness  bool // the input is signed with a witness
+	eligible bool // the input can be used for the shared secret derivation
+}
+
+// builderOutput is an output of the transaction before the silent payment outputs are known
+type builderOutput struct {
+	*BuiltTxOut
+	recipient *Recipient // set for silent payment outputs
+}
+
+// Build selects inputs from utxos to pay payments and returns the unsigned transaction.
+//
+// Inputs which can be used for the shared secret derivation are preferred.
+// Utxos paying to P2TR, P2WPKH, P2PKH or P2SH-P2WPKH are only selected if the SecretKey or Signer
+// belongs to the key in the script, the receiver uses all of them for the shared secret derivation.
+// For taproot that is the output key, e.g. the tweaked key for BIP86.
+// For P2SH-P2WPKH the ScriptSig has to hold the push of the redeem script.
+// Other utxos are only selected if their ScriptSig and Witness hold templates of the size of the signed input.
+// Taproot inputs are assumed to be spent via the key path.
+// Utxos spending segwit versions above 1 are never selected.
+//
+// Transactions paying silent payment addresses or change need at least one eligible input,
+// ErrNoEligibleVins is returned otherwise. ErrInsufficientFunds is returned if the utxos can not pay for the payments and the fee.
+func (b *TxBuilder) Build(utxos []*Vin, payments []Payment) (*UnsignedTx, error) {
+	if len(payments) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	network := b.changeKey.Network()
+
+	var (
+		outputs        []*builderOutput
+		target         uint64
+		outputsWeight  int
+		silentPayments bool
+	)
+	for _, payment := range payments {
+		if payment.Amount == 0 || payment.Amount > btcutil.MaxSatoshi {
+			return nil, ErrInvalidAmount
+		}
+		target += payment.Amount
+
+		output, err := newBuilderOutput(payment, network)
+		if err != nil {
+			return nil, err
+		}
+		silentPayments = silentPayments || output.recipient != nil
+		outputsWeight += txOutWeight(len(output.PkScript))
+		outputs = append(outputs, output)
+	}
+	if target > btcutil.MaxSatoshi {
+		return nil, ErrInvalidAmount
+	}
+
+	candidates := builderUtxos(utxos)
+
+	// eligible inputs first, larger inputs first
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].eligible != candidates[j].eligible {
+			return candidates[i].eligible
+		}
+		return candidates[i].vin.Amount > candidates[j].vin.Amount
+	})
+
+	var (
+		selected      []*builderUtxo
+		total         uint64
+		inputsWeight  int
+		witness       bool
+		fee           uint64
+		change        uint64
+		enough        bool
+		hasEligible   bool
+		needsEligible bool
+		changeWeight  = txOutWeight(len(P2TRScript(Zero32)))
+	)
+	for _, candidate := range candidates {
+		selected = append(selected, candidate)
+		total += candidate.vin.Amount
+		inputsWeight += candidate.weight
+		witness = witness || candidate.witness
+		hasEligible = hasEligible || candidate.eligible
+
+		weight := txOverheadWeight + inputsWeight + outputsWeight
+		if witness {
+			weight += segwitFlagWeight
+		}
+
+		// change is paid to a silent payment address, it needs an eligible input as well
+		feeWithChange := b.fee(weight + changeWeight)
+		if total >= target+feeWithChange+changeDustLimit {
+			if !hasEligible {
+				needsEligible = true
+				continue
+			}
+			fee, change, enough = feeWithChange, total-target-feeWithChange, true
+			break
+		}
+
+		// the remainder is too small for change and goes to the fee
+		feeNoChange := b.fee(weight)
+		if total >= target+feeNoChange {
+			if silentPayments && !hasEligible {
+				needsEligible = true
+				continue
+			}
+			fee, enough = total-target, true
+			break
+		}
+	}
+	if !enough {
+		// eligible utxos are selected first, there are none
+		if needsEligible || (silentPayments && !hasEligible) {
+			return nil, ErrNoEligibleVins
+		}
+		return nil, ErrInsufficientFunds
+	}
+
+	if change > 0 {
+		outputs = append(outputs, &builderOutput{
+			BuiltTxOut: &BuiltTxOut{Address: b.changeAddress, Amount: change, Change: true},
+			recipient:  &Recipient{SilentPaymentAddress: b.changeAddress, Amount: change},
+		})
+	}
+
+	vins := make([]*Vin, len(selected))
+	for i, utxo := range selected {
+		vins[i] = utxo.vin
+	}
+
+	err := b.createSilentPaymentOutputs(outputs, selected, network)
+	if err != nil {
+		return nil, err
+	}
+
+	err = orderOutputs(
+		len(outputs),
+		b.outputOrder,
+		func(i int) (uint64, []byte) { return outputs[i].Amount, outputs[i].PkScript },
+		func(i, j int) { outputs[i], outputs[j] = outputs[j], outputs[i] },
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := wire.NewMsgTx(2)
+	for _, vin := range vins {
+		var outpoint wire.OutPoint
+		// Vin holds the txid in the human-readable format, the outpoint in internal byte order
+		copy(outpoint.Hash[:], ReverseBytesCopy(vin.Txid[:]))
+		outpoint.Index = vin.Vout
+
+		txIn := wire.NewTxIn(&outpoint, nil, nil)
+		txIn.Sequence = wire.MaxTxInSequenceNum - 2 // signals replaceability
+		tx.AddTxIn(txIn)
+	}
+
+	builtOutputs := make([]*BuiltTxOut, len(outputs))
+	for i, output := range outputs {
+		tx.AddTxOut(wire.NewTxOut(int64(output.Amount), output.PkScript))
+		builtOutputs[i] = output.BuiltTxOut
+	}
+
+	return &UnsignedTx{
+		Tx:      tx,
+		Vins:    vins,
+		Outputs: builtOutputs,
+		Fee:     fee,
+	}, nil
+}
+
+// createSilentPaymentOutputs derives the outputs of the silent payment recipients and sets their scripts
+func (b *TxBuilder) createSilentPaymentOutputs(outputs []*builderOutput, selected []*builderUtxo, network Network) error {
+	var recipients []*Recipient
+	for _, output := range outputs {
+		if output.recipient != nil {
+			recipients = append(recipients, output.recipient)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	// the copies carry the taproot flag needed for the negation of the keys
+	vins := make([]*Vin, len(selected))
+	var vinsSharedDerivation []*Vin
+	for i, utxo := range selected {
+		vins[i] = utxo.vin.DeepCopy()
+		if utxo.eligible {
+			vins[i].Taproot = IsP2TR(utxo.vin.ScriptPubKey)
+			vinsSharedDerivation = append(vinsSharedDerivation, vins[i])
+		}
+	}
+
+	err := senderCreateOutputs(recipients, vins, vinsSharedDerivation, network)
+	if err != nil {
+		return err
+	}
+
+	senderOutputs, err := NewSenderOutputs(recipients)
+	if err != nil {
+		return err
+	}
+
+	i := 0
+	for _, output := range outputs {
+		if output.recipient == nil {
+			continue
+		}
+		output.SilentPayment = senderOutputs[i]
+		output.PkScript = senderOutputs[i].PkScript
+		i++
+	}
+
+	return nil
+}
+
+// fee returns the fee for a transaction of the given weight
+func (b *TxBuilder) fee(weight int) uint64 {
+	vSize := (weight + 3) / 4
+	return uint64(vSize) * b.feeRate
+}
+
+// newBuilderOutput decodes the address of a payment.
+// The script of silent payment outputs is set once the inputs are known,
+// the length of the script is already that of the P2TR output.
+func newBuilderOutput(payment Payment, network Network) (*builderOutput, error) {
+	output := &builderOutput{
+		BuiltTxOut: &BuiltTxOut{Address: payment.Address, Amount: payment.Amount},
+	}
+
+	if IsSilentPaymentAddress(payment.Address) {
+		_, _, err := DecodeSilentPaymentAddressToKeys(payment.Address, network)
+		if err != nil {
+			return nil, err
+		}
+		output.recipient = &Recipient{SilentPaymentAddress: payment.Address, Amount: payment.Amount}
+		output.PkScript = P2TRScript(Zero32)
+		return output, nil
+	}
+
+	address, err := btcutil.DecodeAddress(payment.Address, network.Params())
+	if err != nil {
+		return nil, err
+	}
+	if !address.IsForNet(network.Params()) {
+		return nil, AddressHRPError
+	}
+
+	output.PkScript, err = txscript.PayToAddrScript(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return output, nil
+}
+
+// builderUtxos returns the utxos which can be selected as inputs
+func builderUtxos(utxos []*Vin) []*builderUtxo {
+	var candidates []*builderUtxo
+	for _, vin := range utxos {
+		if spendsSegwitVersionAboveOne([]*Vin{vin}) {
+			continue
+		}
+
+		candidate := &builderUtxo{vin: vin}
+		pubKey, hasKey := builderPubKey(vin)
+		hash := Hash160(pubKey[:])
+
+		// the receiver uses every input of these types for the shared secret derivation,
+		// without the matching key the outputs could not be found
+		switch {
+		case IsP2TR(vin.ScriptPubKey):
+			if !hasKey || !bytes.Equal(pubKey[1:], vin.ScriptPubKey[2:]) {
+				continue
+			}
+			candidate.weight, candidate.witness = txInBaseWeight+p2trWitnessWeight, true
+			candidate.eligible = true
+		case IsP2WPKH(vin.ScriptPubKey):
+			if !hasKey || !bytes.Equal(hash, vin.ScriptPubKey[2:]) {
+				continue
+			}
+			candidate.weight, candidate.witness = txInBaseWeight+p2wpkhWitnessWeight, true
+			candidate.eligible = true
+		case IsP2PKH(vin.ScriptPubKey):
+			if !hasKey || !bytes.Equal(hash, vin.ScriptPubKey[3:23]) {
+				continue
+			}
+			candidate.weight = txInBaseWeight + p2pkhScriptSigWeight
+			candidate.eligible = true
+		case IsP2SH(vin.ScriptPubKey) && len(vin.ScriptSig) == 23 && IsP2WPKH(vin.ScriptSig[1:]):
+			if !hasKey || !isP2SHP2WPKHScriptSig(vin.ScriptSig, vin.ScriptPubKey, hash) {
+				continue
+			}
+			candidate.weight, candidate.witness = txInBaseWeight+p2shP2WPKHScriptSigWeight+p2wpkhWitnessWeight, true
+			candidate.eligible = true
+		case len(vin.ScriptSig) > 0 || len(vin.Witness) > 0:
+			// the templates have the size of the signed input
+			txIn := wire.TxIn{SignatureScript: vin.ScriptSig, Witness: vin.Witness}
+			candidate.weight = txIn.SerializeSize()*4 + txIn.Witness.SerializeSize()
+			candidate.witness = len(vin.Witness) > 0
+		default:
+			continue
+		}
+
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// builderPubKey returns the public key of a vin with its secret key or signer.
+// Unlike signingPubKey the parity of taproot keys is kept.
+func builderPubKey(vin *Vin) ([33]byte, bool) {
+	switch {
+	case vin.SecretKey != nil:
+		if _, err := NewPrivateKeySigner(*vin.SecretKey); err != nil {
+			return [33]byte{}, false
+		}
+		return *PubKeyFromSecKey(vin.SecretKey), true
+	case vin.Signer != nil:
+		return vin.Signer.PubKey(), true
+	default:
+		return [33]byte{}, false
+	}
+}
+
+// isP2SHP2WPKHScriptSig checks whether scriptSig pushes the P2WPKH redeem script for pubKeyHash of the P2SH script
+func isP2SHP2WPKHScriptSig(scriptSig, scriptPubKey, pubKeyHash []byte) bool {
+	if len(scriptSig) != 23 || scriptSig[0] != txscript.OP_DATA_22 {
+		return false
+	}
+	redeemScript := scriptSig[1:]
+	return IsP2WPKH(redeemScript) &&
+		bytes.Equal(redeemScript[2:], pubKeyHash) &&
+		bytes.Equal(Hash160(redeemScript), scriptPubKey[2:22])
+}
+
+// txOutWeight returns the weight of an output with a script of the given length
+func txOutWeight(pkScriptLength int) int {
+	return wire.NewTxOut(0, make([]byte, pkScriptLength)).SerializeSize() * 4
+}
+
+// PrevOutFetcher returns the prevouts of the inputs, as needed to compute the signature hashes
+func (u *UnsignedTx) PrevOutFetcher() *txscript.MultiPrevOutFetcher {
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, vin := range u.Vins {
+		fetcher.AddPrevOut(u.Tx.TxIn[i].PreviousOutPoint, wire.NewTxOut(int64(vin.Amount), vin.ScriptPubKey))
+	}
+	return fetcher
+}
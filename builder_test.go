@@ -0,0 +1,255 @@
+package bip352
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// builderTestUtxo creates a utxo with a secret key paying to the script returned by pkScript
+func builderTestUtxo(name string, vout uint32, amount uint64, pkScript func(pubKey *btcec.PublicKey) []byte) *Vin {
+	secretKey := sha256.Sum256([]byte(name))
+	_, pubKey := btcec.PrivKeyFromBytes(secretKey[:])
+
+	return &Vin{
+		Txid:         sha256.Sum256([]byte("txid " + name)),
+		Vout:         vout,
+		Amount:       amount,
+		SecretKey:    &secretKey,
+		ScriptPubKey: pkScript(pubKey),
+	}
+}
+
+func builderTestP2TR(pubKey *btcec.PublicKey) []byte {
+	return P2TRScript([32]byte(pubKey.SerializeCompressed()[1:]))
+}
+
+func builderTestP2WPKH(pubKey *btcec.PublicKey) []byte {
+	return append([]byte{txscript.OP_0, txscript.OP_DATA_20}, Hash160(pubKey.SerializeCompressed())...)
+}
+
+// builderTestP2WSH pays to a witness script without a key, it is not eligible
+func builderTestP2WSH(*btcec.PublicKey) []byte {
+	scriptHash := sha256.Sum256([]byte{txscript.OP_TRUE})
+	return append([]byte{txscript.OP_0, txscript.OP_DATA_32}, scriptHash[:]...)
+}
+
+// builderTestSign signs the P2TR and P2WPKH inputs of the transaction
+func builderTestSign(t *testing.T, unsignedTx *UnsignedTx) {
+	fetcher := unsignedTx.PrevOutFetcher()
+	sigHashes := txscript.NewTxSigHashes(unsignedTx.Tx, fetcher)
+
+	for i, vin := range unsignedTx.Vins {
+		privKey, _ := btcec.PrivKeyFromBytes(vin.SecretKey[:])
+
+		var err error
+		if IsP2TR(vin.ScriptPubKey) {
+			var sig []byte
+			sig, err = txscript.RawTxInTaprootSignature(
+				unsignedTx.Tx, sigHashes, i, int64(vin.Amount), vin.ScriptPubKey, nil, txscript.SigHashDefault, privKey,
+			)
+			unsignedTx.Tx.TxIn[i].Witness = wire.TxWitness{sig}
+		} else {
+			unsignedTx.Tx.TxIn[i].Witness, err = txscript.WitnessSignature(
+				unsignedTx.Tx, sigHashes, i, int64(vin.Amount), vin.ScriptPubKey, txscript.SigHashAll, privKey, true,
+			)
+		}
+		require.NoError(t, err)
+	}
+}
+
+func TestTxBuilder(t *testing.T) {
+	senderScanSecKey := sha256.Sum256([]byte("sender scan"))
+	senderSpendSecKey := sha256.Sum256([]byte("sender spend"))
+	senderKey, err := NewWatchOnlyKey(senderScanSecKey, *PubKeyFromSecKey(&senderSpendSecKey), 0, Mainnet)
+	require.NoError(t, err)
+
+	receiverScanSecKey := sha256.Sum256([]byte("receiver scan"))
+	receiverSpendSecKey := sha256.Sum256([]byte("receiver spend"))
+	receiverKey, err := NewWatchOnlyKey(receiverScanSecKey, *PubKeyFromSecKey(&receiverSpendSecKey), 0, Mainnet)
+	require.NoError(t, err)
+	receiverAddress, err := receiverKey.Address()
+	require.NoError(t, err)
+
+	regularAddress, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), Mainnet.Params())
+	require.NoError(t, err)
+
+	utxos := []*Vin{
+		// the largest utxo is not eligible and must not be selected
+		builderTestUtxo("p2wsh", 0, 1_000_000, builderTestP2WSH),
+		builderTestUtxo("p2wpkh", 1, 50_000, builderTestP2WPKH),
+		builderTestUtxo("p2tr", 2, 100_000, builderTestP2TR),
+	}
+	utxos[0].Witness = [][]byte{{txscript.OP_TRUE}}
+
+	payments := []Payment{
+		{Address: receiverAddress.String(), Amount: 90_000},
+		{Address: regularAddress.EncodeAddress(), Amount: 20_000},
+	}
+
+	const feeRate = 3
+	builder, err := NewTxBuilder(senderKey, feeRate, OutputOrderBIP69)
+	require.NoError(t, err)
+
+	unsignedTx, err := builder.Build(utxos, payments)
+	require.NoError(t, err)
+
+	// both eligible utxos are needed, the larger one first
+	require.Equal(t, []*Vin{utxos[2], utxos[1]}, unsignedTx.Vins)
+	require.Len(t, unsignedTx.Tx.TxIn, 2)
+	require.Len(t, unsignedTx.Tx.TxOut, 3)
+	require.Len(t, unsignedTx.Outputs, 3)
+
+	var outputsTotal uint64
+	var change *BuiltTxOut
+	for i, output := range unsignedTx.Outputs {
+		txOut := unsignedTx.Tx.TxOut[i]
+		require.Equal(t, int64(output.Amount), txOut.Value)
+		require.Equal(t, output.PkScript, txOut.PkScript)
+		outputsTotal += output.Amount
+
+		if i > 0 {
+			require.LessOrEqual(t, unsignedTx.Tx.TxOut[i-1].Value, txOut.Value)
+		}
+
+		switch {
+		case output.Change:
+			change = output
+			require.Equal(t, builder.ChangeAddress(), output.Address)
+			require.NotNil(t, output.SilentPayment)
+		case output.Address == receiverAddress.String():
+			require.NotNil(t, output.SilentPayment)
+			require.Equal(t, uint32(0), output.SilentPayment.K)
+			require.True(t, IsP2TR(output.PkScript))
+		default:
+			require.Nil(t, output.SilentPayment)
+			require.Equal(t, uint64(20_000), output.Amount)
+		}
+	}
+	require.NotNil(t, change)
+	require.Equal(t, uint64(150_000), outputsTotal+unsignedTx.Fee)
+
+	builderTestSign(t, unsignedTx)
+
+	// the fee pays for the signed transaction, the estimate may only be slightly above it
+	weight := unsignedTx.Tx.SerializeSizeStripped()*3 + unsignedTx.Tx.SerializeSize()
+	vSize := uint64((weight + 3) / 4)
+	require.GreaterOrEqual(t, unsignedTx.Fee, vSize*feeRate)
+	require.LessOrEqual(t, unsignedTx.Fee, (vSize+2)*feeRate)
+
+	// the receiver finds the payment
+	fetcher := unsignedTx.PrevOutFetcher()
+	found, err := receiverKey.ScanTx(unsignedTx.Tx, fetcher, nil)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, uint64(90_000), found[0].Amount)
+
+	// the sender finds the change with the change label
+	labelManager, err := senderKey.LabelManager()
+	require.NoError(t, err)
+	found, err = senderKey.ScanTx(unsignedTx.Tx, fetcher, labelManager.LabelSet())
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, change.Amount, found[0].Amount)
+	require.NotNil(t, found[0].LabelM)
+	require.Equal(t, uint32(0), *found[0].LabelM)
+}
+
+func TestTxBuilderErrors(t *testing.T) {
+	senderScanSecKey := sha256.Sum256([]byte("sender scan"))
+	senderSpendSecKey := sha256.Sum256([]byte("sender spend"))
+	senderKey, err := NewWatchOnlyKey(senderScanSecKey, *PubKeyFromSecKey(&senderSpendSecKey), 0, Mainnet)
+	require.NoError(t, err)
+
+	builder, err := NewTxBuilder(senderKey, 1, OutputOrderRandom)
+	require.NoError(t, err)
+
+	regularAddress, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), Mainnet.Params())
+	require.NoError(t, err)
+	testnetAddress, err := btcutil.NewAddressWitnessPubKeyHash(make([]byte, 20), Testnet.Params())
+	require.NoError(t, err)
+
+	eligible := []*Vin{builderTestUtxo("p2tr", 0, 10_000, builderTestP2TR)}
+	p2wsh := builderTestUtxo("p2wsh", 0, 1_000_000, builderTestP2WSH)
+	p2wsh.Witness = [][]byte{{txscript.OP_TRUE}}
+
+	_, err = builder.Build(eligible, nil)
+	require.ErrorIs(t, err, ErrNoRecipients)
+
+	_, err = builder.Build(eligible, []Payment{{Address: regularAddress.EncodeAddress(), Amount: 0}})
+	require.ErrorIs(t, err, ErrInvalidAmount)
+
+	_, err = builder.Build(eligible, []Payment{{Address: testnetAddress.EncodeAddress(), Amount: 1000}})
+	require.Error(t, err)
+
+	_, err = builder.Build(eligible, []Payment{{Address: testAddress, Amount: 10_000}})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// silent payments and change need an eligible input
+	_, err = builder.Build([]*Vin{p2wsh}, []Payment{{Address: testAddress, Amount: 1000}})
+	require.ErrorIs(t, err, ErrNoEligibleVins)
+	_, err = builder.Build([]*Vin{p2wsh}, []Payment{{Address: regularAddress.EncodeAddress(), Amount: 1000}})
+	require.ErrorIs(t, err, ErrNoEligibleVins)
+
+	// without change the ineligible utxo can pay a regular address
+	unsignedTx, err := builder.Build([]*Vin{p2wsh}, []Payment{{Address: regularAddress.EncodeAddress(), Amount: 999_800}})
+	require.NoError(t, err)
+	require.Len(t, unsignedTx.Tx.TxOut, 1)
+	require.Equal(t, uint64(200), unsignedTx.Fee)
+
+	// utxos without key and without templates can not be sized
+	_, err = builder.Build([]*Vin{builderTestUtxo("p2wsh", 0, 1_000_000, builderTestP2WSH)}, []Payment{{Address: regularAddress.EncodeAddress(), Amount: 1000}})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+}
+
+func TestTxBuilderKeyMismatch(t *testing.T) {
+	senderScanSecKey := sha256.Sum256([]byte("sender scan"))
+	senderSpendSecKey := sha256.Sum256([]byte("sender spend"))
+	senderKey, err := NewWatchOnlyKey(senderScanSecKey, *PubKeyFromSecKey(&senderSpendSecKey), 0, Mainnet)
+	require.NoError(t, err)
+
+	receiverScanSecKey := sha256.Sum256([]byte("receiver scan"))
+	receiverSpendSecKey := sha256.Sum256([]byte("receiver spend"))
+	receiverKey, err := NewWatchOnlyKey(receiverScanSecKey, *PubKeyFromSecKey(&receiverSpendSecKey), 0, Mainnet)
+	require.NoError(t, err)
+	receiverAddress, err := receiverKey.Address()
+	require.NoError(t, err)
+
+	// the receiver uses these utxos for the shared secret derivation, the sender can not
+	noKey := builderTestUtxo("no key", 0, 1_000_000, builderTestP2WPKH)
+	noKey.SecretKey = nil
+	// BIP86 output key with the internal key
+	bip86 := builderTestUtxo("bip86", 1, 1_000_000, func(pubKey *btcec.PublicKey) []byte {
+		return P2TRScript([32]byte(schnorr.SerializePubKey(txscript.ComputeTaprootKeyNoScript(pubKey))))
+	})
+	otherKey := builderTestUtxo("other key", 2, 1_000_000, builderTestP2WPKH)
+	otherSecKey := sha256.Sum256([]byte("other"))
+	otherKey.SecretKey = &otherSecKey
+	mismatched := []*Vin{noKey, bip86, otherKey}
+
+	builder, err := NewTxBuilder(senderKey, 1, OutputOrderRecipients)
+	require.NoError(t, err)
+	payments := []Payment{{Address: receiverAddress.String(), Amount: 50_000}}
+
+	_, err = builder.Build(mismatched, payments)
+	require.ErrorIs(t, err, ErrNoEligibleVins)
+
+	matching := builderTestUtxo("p2tr", 3, 100_000, builderTestP2TR)
+	unsignedTx, err := builder.Build(append(mismatched, matching), payments)
+	require.NoError(t, err)
+	require.Equal(t, []*Vin{matching}, unsignedTx.Vins)
+
+	builderTestSign(t, unsignedTx)
+
+	// the receiver finds the payment
+	found, err := receiverKey.ScanTx(unsignedTx.Tx, unsignedTx.PrevOutFetcher(), nil)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, uint64(50_000), found[0].Amount)
+}
@@ -8,7 +8,6 @@ import (
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/setavenger/blindbit-lib/utils"
-	golibsecp256k1 "github.com/setavenger/go-libsecp256k1"
 )
 
 type TypeUTXO int8
@@ -21,6 +20,11 @@ const (
 	P2SH
 )
 
+// kMax is the maximum number of outputs per recipient group, k runs from 0 to kMax-1.
+// Senders return ErrTooManyGroupOutputs above the limit and receivers stop scanning a group once it is reached,
+// so an adversarial transaction can not make a receiver loop over an unbounded number of outputs.
+const kMax uint32 = 2323
+
 // CreateOutputPubKey
 // returns 32 byte x-only pubKey
 func CreateOutputPubKey(
@@ -102,7 +106,7 @@ func CreateLabel(scanSecKey *[32]byte, m uint32) (Label, error) {
 		return Label{}, err
 	}
 
-	labelPubKey := golibsecp256k1.PubKeyFromSecKey(&labelTweak)
+	labelPubKey := pubKeyFromSecKey(&labelTweak)
 
 	return Label{Tweak: labelTweak, PubKey: *labelPubKey, M: m}, err
 }
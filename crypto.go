@@ -1,9 +1,11 @@
 package bip352
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"fmt"
 
-	golibsecp256k1 "github.com/setavenger/go-libsecp256k1"
+	"github.com/btcsuite/btcd/btcec/v2"
 	"golang.org/x/crypto/ripemd160"
 )
 
@@ -22,23 +24,34 @@ import (
 //
 // shared_secret = b_scan * A_tweaked   [Receiver, Light client scenario]
 //
-// publicComponent is modified in place
+// The arguments are not modified, the shared secret is returned in a new array.
 func CreateSharedSecret(
 	publicComponent *[33]byte,
 	secretComponent *[32]byte,
 	inputHash *[32]byte,
+) (*[33]byte, error) {
+	publicComponentCopy := *publicComponent
+	secretComponentCopy := *secretComponent
+	return CreateSharedSecretInPlace(&publicComponentCopy, &secretComponentCopy, inputHash)
+}
+
+// CreateSharedSecretInPlace is CreateSharedSecret without copying the arguments.
+// publicComponent is overwritten with the shared secret and returned,
+// secretComponent is multiplied by inputHash if inputHash is not nil.
+func CreateSharedSecretInPlace(
+	publicComponent *[33]byte,
+	secretComponent *[32]byte,
+	inputHash *[32]byte,
 ) (*[33]byte, error) {
 	var err error
 	if inputHash != nil {
-		err = golibsecp256k1.MultPrivateKeys(secretComponent, inputHash)
+		err = secKeyMul(secretComponent, inputHash)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	// todo: publicComponent is modified in place and the api does not properlu shows this
-	// despite the comment above not ideal
-	err = golibsecp256k1.PubKeyTweakMul(publicComponent, secretComponent)
+	err = pubKeyTweakMul(publicComponent, secretComponent)
 	if err != nil {
 		return nil, err
 	}
@@ -46,24 +59,222 @@ func CreateSharedSecret(
 	return publicComponent, nil
 }
 
+// GenerateDLEQProof generates a BIP374 proof that C = a*B and A = a*G share the same secret a,
+// without revealing a. This allows a party to prove that an ECDH share a_i*B_scan was computed honestly.
+// auxRand: 32 bytes of fresh randomness, required
+// generator: G, can be nil to use the secp256k1 generator
+// message: optional 32 byte message that is committed to in the proof
+func GenerateDLEQProof(
+	secretKey *[32]byte,
+	publicKey *[33]byte,
+	auxRand *[32]byte,
+	generator *[33]byte,
+	message *[32]byte,
+) ([64]byte, error) {
+	if secretKey == nil {
+		return [64]byte{}, ErrInvalidSecretKey
+	}
+	if publicKey == nil {
+		return [64]byte{}, ErrInvalidPublicKey
+	}
+	if auxRand == nil {
+		return [64]byte{}, fmt.Errorf("%w: aux randomness missing", ErrDLEQProofGeneration)
+	}
+
+	var a btcec.ModNScalar
+	if overflow := a.SetBytes(secretKey); overflow != 0 || a.IsZero() {
+		return [64]byte{}, ErrInvalidSecretKey
+	}
+
+	B, err := btcec.ParseJacobian(publicKey[:])
+	if err != nil {
+		return [64]byte{}, ErrInvalidPublicKey
+	}
+
+	G, err := dleqGenerator(generator)
+	if err != nil {
+		return [64]byte{}, err
+	}
+
+	// A = a*G, C = a*B
+	var A, C btcec.JacobianPoint
+	btcec.ScalarMultNonConst(&a, &G, &A)
+	btcec.ScalarMultNonConst(&a, &B, &C)
+
+	// t = bytes(a) xor hash_BIP0374/aux(r)
+	auxHash := TaggedHash("BIP0374/aux", auxRand[:])
+	var t [32]byte
+	for i := range t {
+		t[i] = secretKey[i] ^ auxHash[i]
+	}
+
+	var msg []byte
+	if message != nil {
+		msg = message[:]
+	}
+
+	// k = hash_BIP0374/nonce(t || A || C || m) mod n
+	nonceData := bytes.Join([][]byte{t[:], cbytes(A), cbytes(C), msg}, nil)
+	rand := TaggedHash("BIP0374/nonce", nonceData)
+	var k btcec.ModNScalar
+	k.SetBytes(&rand)
+	if k.IsZero() {
+		return [64]byte{}, ErrDLEQProofGeneration
+	}
+
+	// R1 = k*G, R2 = k*B
+	var R1, R2 btcec.JacobianPoint
+	btcec.ScalarMultNonConst(&k, &G, &R1)
+	btcec.ScalarMultNonConst(&k, &B, &R2)
+
+	challenge := dleqChallenge(A, B, C, R1, R2, G, msg)
+
+	// s = k + e*a
+	var s btcec.ModNScalar
+	s.SetBytes(&challenge)
+	s.Mul(&a).Add(&k)
+
+	var proof [64]byte
+	copy(proof[:32], challenge[:])
+	s.PutBytesUnchecked(proof[32:])
+
+	var pubKeyA, pubKeyC [33]byte
+	copy(pubKeyA[:], cbytes(A))
+	copy(pubKeyC[:], cbytes(C))
+	if !VerifyDLEQProof(&pubKeyA, publicKey, &pubKeyC, &proof, generator, message) {
+		return [64]byte{}, ErrDLEQProofGeneration
+	}
+
+	return proof, nil
+}
+
+// VerifyDLEQProof verifies a BIP374 proof that C = a*B for the a in A = a*G.
+// generator and message have to be the same as used for the proof generation.
+func VerifyDLEQProof(
+	publicKeyA, publicKeyB, publicKeyC *[33]byte,
+	proof *[64]byte,
+	generator *[33]byte,
+	message *[32]byte,
+) bool {
+	A, errA := btcec.ParseJacobian(publicKeyA[:])
+	B, errB := btcec.ParseJacobian(publicKeyB[:])
+	C, errC := btcec.ParseJacobian(publicKeyC[:])
+	if errA != nil || errB != nil || errC != nil {
+		return false
+	}
+
+	G, err := dleqGenerator(generator)
+	if err != nil {
+		return false
+	}
+
+	var e, s btcec.ModNScalar
+	var sBytes [32]byte
+	copy(sBytes[:], proof[32:])
+	e.SetByteSlice(proof[:32])
+	if overflow := s.SetBytes(&sBytes); overflow != 0 {
+		return false
+	}
+
+	// R1 = s*G - e*A, R2 = s*B - e*C
+	var eNeg btcec.ModNScalar
+	eNeg.NegateVal(&e)
+
+	R1 := linearCombination(&s, &G, &eNeg, &A)
+	R2 := linearCombination(&s, &B, &eNeg, &C)
+	if isInfinity(&R1) || isInfinity(&R2) {
+		return false
+	}
+
+	var msg []byte
+	if message != nil {
+		msg = message[:]
+	}
+
+	challenge := dleqChallenge(A, B, C, R1, R2, G, msg)
+	return bytes.Equal(challenge[:], proof[:32])
+}
+
+// dleqChallenge e = hash_BIP0374/challenge(A || B || C || G || R1 || R2 || m)
+func dleqChallenge(A, B, C, R1, R2, G btcec.JacobianPoint, message []byte) [32]byte {
+	data := bytes.Join([][]byte{
+		cbytes(A), cbytes(B), cbytes(C), cbytes(G), cbytes(R1), cbytes(R2), message,
+	}, nil)
+	return TaggedHash("BIP0374/challenge", data)
+}
+
+func dleqGenerator(generator *[33]byte) (btcec.JacobianPoint, error) {
+	var G btcec.JacobianPoint
+	if generator == nil {
+		btcec.GeneratorJacobian(&G)
+		return G, nil
+	}
+
+	G, err := btcec.ParseJacobian(generator[:])
+	if err != nil {
+		return G, ErrInvalidPublicKey
+	}
+	return G, nil
+}
+
+// linearCombination returns k1*P1 + k2*P2
+func linearCombination(k1 *btcec.ModNScalar, P1 *btcec.JacobianPoint, k2 *btcec.ModNScalar, P2 *btcec.JacobianPoint) btcec.JacobianPoint {
+	var first, second, result btcec.JacobianPoint
+	btcec.ScalarMultNonConst(k1, P1, &first)
+	btcec.ScalarMultNonConst(k2, P2, &second)
+	btcec.AddNonConst(&first, &second, &result)
+	return result
+}
+
+func isInfinity(point *btcec.JacobianPoint) bool {
+	return (point.X.IsZero() && point.Y.IsZero()) || point.Z.IsZero()
+}
+
+// parseScalar parses a 32 byte big-endian scalar, values >= n are rejected
+func parseScalar(b *[32]byte) (btcec.ModNScalar, error) {
+	var s btcec.ModNScalar
+	if overflow := s.SetBytes(b); overflow != 0 {
+		return s, fmt.Errorf("%w: scalar overflows the group order", ErrTweak)
+	}
+	return s, nil
+}
+
+// parseTweak parses a scalar used to multiply a point, zero is rejected as the product would be the point at infinity
+func parseTweak(b *[32]byte) (btcec.ModNScalar, error) {
+	t, err := parseScalar(b)
+	if err != nil {
+		return t, err
+	}
+	if t.IsZero() {
+		return t, fmt.Errorf("%w: tweak is zero", ErrTweak)
+	}
+	return t, nil
+}
+
+// cbytes returns the 33 byte compressed serialisation of a point
+func cbytes(point btcec.JacobianPoint) []byte {
+	point.ToAffine()
+	return btcec.NewPublicKey(&point.X, &point.Y).SerializeCompressed()
+}
+
 func AddPublicKeys(publicKeyBytes1, publicKeyBytes2 *[33]byte) ([33]byte, error) {
-	return golibsecp256k1.PubKeyAdd(publicKeyBytes1, publicKeyBytes2)
+	return pubKeyAdd(publicKeyBytes1, publicKeyBytes2)
 }
 
 func AddPrivateKeys(secKey1, secKey2 *[32]byte) error {
-	return golibsecp256k1.SecKeyAdd(secKey1, secKey2)
+	return secKeyAdd(secKey1, secKey2)
 }
 
 func NegatePublicKey(pk *[33]byte) error {
-	return golibsecp256k1.PubKeyNegate(pk)
+	return pubKeyNegate(pk)
 }
 
 func MultPrivateKeys(secKey1, secKey2 *[32]byte) error { // modify to return error instead of new key. work with pointers
-	return golibsecp256k1.MultPrivateKeys(secKey1, secKey2)
+	return secKeyMul(secKey1, secKey2)
 }
 
 func PubKeyFromSecKey(secKey *[32]byte) *[33]byte {
-	return golibsecp256k1.PubKeyFromSecKey(secKey)
+	return pubKeyFromSecKey(secKey)
 }
 
 func SumPublicKeys(pubKeys [][33]byte) (out *[33]byte, err error) {
@@ -73,7 +284,7 @@ func SumPublicKeys(pubKeys [][33]byte) (out *[33]byte, err error) {
 		if idx == 0 {
 			lastPubKey = pubKey
 		} else {
-			lastPubKey, err = golibsecp256k1.PubKeyAdd(&lastPubKey, &pubKey)
+			lastPubKey, err = pubKeyAdd(&lastPubKey, &pubKey)
 			if err != nil {
 				return nil, err
 			}
@@ -0,0 +1,222 @@
+package bip352
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+)
+
+func randomSecretKey(t *testing.T) [32]byte {
+	var secretKey [32]byte
+	_, err := rand.Read(secretKey[:])
+	require.NoError(t, err)
+	return secretKey
+}
+
+func TestCreateSharedSecret(t *testing.T) {
+	secretKey := randomSecretKey(t)
+	publicSecretKey := randomSecretKey(t)
+	inputHash := randomSecretKey(t)
+	publicKey := *PubKeyFromSecKey(&publicSecretKey)
+
+	secretKeyBefore, publicKeyBefore, inputHashBefore := secretKey, publicKey, inputHash
+
+	// shared_secret = (a * input_hash) * B = a * (input_hash * B)
+	sharedSecret, err := CreateSharedSecret(&publicKey, &secretKey, &inputHash)
+	require.NoError(t, err)
+	tweak, err := CreateSharedSecret(&publicKey, &inputHash, nil)
+	require.NoError(t, err)
+	expected, err := CreateSharedSecret(tweak, &secretKey, nil)
+	require.NoError(t, err)
+	require.Equal(t, *expected, *sharedSecret)
+
+	require.Equal(t, secretKeyBefore, secretKey)
+	require.Equal(t, publicKeyBefore, publicKey)
+	require.Equal(t, inputHashBefore, inputHash)
+	require.NotSame(t, &publicKey, sharedSecret)
+
+	// the in place variant writes to the arguments
+	sharedSecretInPlace, err := CreateSharedSecretInPlace(&publicKey, &secretKey, &inputHash)
+	require.NoError(t, err)
+	require.Same(t, &publicKey, sharedSecretInPlace)
+	require.Equal(t, *sharedSecret, publicKey)
+	require.NotEqual(t, secretKeyBefore, secretKey)
+	require.Equal(t, inputHashBefore, inputHash)
+}
+
+func TestDLEQProof(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		secretKey := randomSecretKey(t)
+		scanSecretKey := randomSecretKey(t)
+		auxRand := randomSecretKey(t)
+		message := randomSecretKey(t)
+
+		A := PubKeyFromSecKey(&secretKey)
+		B := PubKeyFromSecKey(&scanSecretKey)
+
+		// the ecdh share C = a*B as it is computed for sending
+		C, err := CreateSharedSecret(B, &secretKey, nil)
+		require.NoError(t, err)
+
+		for _, msg := range []*[32]byte{nil, &message} {
+			proof, err := GenerateDLEQProof(&secretKey, B, &auxRand, nil, msg)
+			require.NoError(t, err)
+			require.True(t, VerifyDLEQProof(A, B, C, &proof, nil, msg))
+
+			// proofs are deterministic for the same aux randomness
+			proofAgain, err := GenerateDLEQProof(&secretKey, B, &auxRand, nil, msg)
+			require.NoError(t, err)
+			require.Equal(t, proof, proofAgain)
+
+			// the statement has to match exactly
+			require.False(t, VerifyDLEQProof(B, A, C, &proof, nil, msg))
+			require.False(t, VerifyDLEQProof(A, B, A, &proof, nil, msg))
+
+			tampered := proof
+			tampered[0] ^= 0x01
+			require.False(t, VerifyDLEQProof(A, B, C, &tampered, nil, msg))
+
+			tampered = proof
+			tampered[63] ^= 0x01
+			require.False(t, VerifyDLEQProof(A, B, C, &tampered, nil, msg))
+		}
+
+		// the message is committed to
+		proof, err := GenerateDLEQProof(&secretKey, B, &auxRand, nil, &message)
+		require.NoError(t, err)
+		require.False(t, VerifyDLEQProof(A, B, C, &proof, nil, nil))
+	}
+}
+
+func TestDLEQProofCustomGenerator(t *testing.T) {
+	secretKey := randomSecretKey(t)
+	generatorSecretKey := randomSecretKey(t)
+	scanSecretKey := randomSecretKey(t)
+	auxRand := randomSecretKey(t)
+
+	G := PubKeyFromSecKey(&generatorSecretKey)
+	B := PubKeyFromSecKey(&scanSecretKey)
+
+	A, err := CreateSharedSecret(G, &secretKey, nil)
+	require.NoError(t, err)
+
+	C, err := CreateSharedSecret(B, &secretKey, nil)
+	require.NoError(t, err)
+
+	proof, err := GenerateDLEQProof(&secretKey, B, &auxRand, G, nil)
+	require.NoError(t, err)
+	require.True(t, VerifyDLEQProof(A, B, C, &proof, G, nil))
+	require.False(t, VerifyDLEQProof(A, B, C, &proof, nil, nil))
+}
+
+func TestDLEQProofInvalidInputs(t *testing.T) {
+	scanSecretKey := randomSecretKey(t)
+	auxRand := randomSecretKey(t)
+	B := PubKeyFromSecKey(&scanSecretKey)
+
+	var zero [32]byte
+	_, err := GenerateDLEQProof(&zero, B, &auxRand, nil, nil)
+	require.ErrorIs(t, err, ErrInvalidSecretKey)
+
+	// curve order n
+	var order = [32]byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe,
+		0xba, 0xae, 0xdc, 0xe6, 0xaf, 0x48, 0xa0, 0x3b, 0xbf, 0xd2, 0x5e, 0x8c, 0xd0, 0x36, 0x41, 0x41,
+	}
+	_, err = GenerateDLEQProof(&order, B, &auxRand, nil, nil)
+	require.ErrorIs(t, err, ErrInvalidSecretKey)
+
+	secretKey := randomSecretKey(t)
+	var invalidPoint = [33]byte{0x02}
+	_, err = GenerateDLEQProof(&secretKey, &invalidPoint, &auxRand, nil, nil)
+	require.ErrorIs(t, err, ErrInvalidPublicKey)
+
+	// aux randomness is required
+	_, err = GenerateDLEQProof(&secretKey, B, nil, nil, nil)
+	require.ErrorIs(t, err, ErrDLEQProofGeneration)
+
+	// s must be smaller than the curve order
+	A := PubKeyFromSecKey(&secretKey)
+	proof, err := GenerateDLEQProof(&secretKey, B, &auxRand, nil, nil)
+	require.NoError(t, err)
+	copy(proof[32:], order[:])
+	require.False(t, VerifyDLEQProof(A, B, A, &proof, nil, nil))
+}
+
+// bip374Proof follows GenerateProof of the BIP374 specification step by step.
+// It uses the affine big.Int arithmetic of btcec.S256 and chainhash.TaggedHash,
+// none of the code paths of GenerateDLEQProof.
+func bip374Proof(a, Bx, By *big.Int, r []byte, Gx, Gy *big.Int, m []byte) [64]byte {
+	curve := btcec.S256()
+	n := curve.Params().N
+	cbytes := func(x, y *big.Int) []byte {
+		var fx, fy btcec.FieldVal
+		fx.SetByteSlice(x.Bytes())
+		fy.SetByteSlice(y.Bytes())
+		return btcec.NewPublicKey(&fx, &fy).SerializeCompressed()
+	}
+
+	// A = a⋅G, C = a⋅B
+	Ax, Ay := curve.ScalarMult(Gx, Gy, a.FillBytes(make([]byte, 32)))
+	Cx, Cy := curve.ScalarMult(Bx, By, a.FillBytes(make([]byte, 32)))
+
+	// t = xor(bytes(32, a), hash_BIP0374/aux(r))
+	t := a.FillBytes(make([]byte, 32))
+	aux := chainhash.TaggedHash([]byte("BIP0374/aux"), r)
+	for i := range t {
+		t[i] ^= aux[i]
+	}
+
+	// rand = hash_BIP0374/nonce(t || cbytes(A) || cbytes(C) || m'), k = int(rand) mod n
+	nonce := chainhash.TaggedHash([]byte("BIP0374/nonce"), t, cbytes(Ax, Ay), cbytes(Cx, Cy), m)
+	k := new(big.Int).Mod(new(big.Int).SetBytes(nonce[:]), n)
+
+	// R1 = k⋅G, R2 = k⋅B
+	R1x, R1y := curve.ScalarMult(Gx, Gy, k.FillBytes(make([]byte, 32)))
+	R2x, R2y := curve.ScalarMult(Bx, By, k.FillBytes(make([]byte, 32)))
+
+	// e = int(hash_BIP0374/challenge(cbytes(A) || cbytes(B) || cbytes(C) || cbytes(G) || cbytes(R1) || cbytes(R2) || m'))
+	e := chainhash.TaggedHash([]byte("BIP0374/challenge"),
+		cbytes(Ax, Ay), cbytes(Bx, By), cbytes(Cx, Cy), cbytes(Gx, Gy), cbytes(R1x, R1y), cbytes(R2x, R2y), m)
+
+	// s = (k + e⋅a) mod n, proof = bytes(32, e) || bytes(32, s)
+	sc := new(big.Int).Mul(new(big.Int).SetBytes(e[:]), a)
+	sc.Add(sc, k).Mod(sc, n)
+
+	var proof [64]byte
+	copy(proof[:32], e[:])
+	sc.FillBytes(proof[32:])
+	return proof
+}
+
+func TestDLEQProofSpecification(t *testing.T) {
+	curve := btcec.S256()
+
+	for i := 0; i < 8; i++ {
+		secretKey := randomSecretKey(t)
+		scanSecretKey := randomSecretKey(t)
+		auxRand := randomSecretKey(t)
+		message := randomSecretKey(t)
+		generatorSecretKey := randomSecretKey(t)
+
+		a := new(big.Int).SetBytes(secretKey[:])
+		Bx, By := curve.ScalarBaseMult(scanSecretKey[:])
+		B := PubKeyFromSecKey(&scanSecretKey)
+
+		// secp256k1 generator without message
+		proof, err := GenerateDLEQProof(&secretKey, B, &auxRand, nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, bip374Proof(a, Bx, By, auxRand[:], curve.Gx, curve.Gy, nil), proof)
+
+		// custom generator with message
+		Gx, Gy := curve.ScalarBaseMult(generatorSecretKey[:])
+		G := PubKeyFromSecKey(&generatorSecretKey)
+		proof, err = GenerateDLEQProof(&secretKey, B, &auxRand, G, &message)
+		require.NoError(t, err)
+		require.Equal(t, bip374Proof(a, Bx, By, auxRand[:], Gx, Gy, message[:]), proof)
+	}
+}
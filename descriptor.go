@@ -0,0 +1,417 @@
+package bip352
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// Descriptor is a silent payment output descriptor as proposed in BIP392.
+// The following forms are parsed:
+//
+//	sp(spscan1...)     scan secret key and spend public key, watch-only
+//	sp(spspend1...)    scan and spend secret key
+//	sp(SCAN,SPEND)     WIF scan secret key and a hex spend public key or WIF spend secret key
+//
+// A trailing BIP380 checksum is verified if present. String always returns the single key form with checksum.
+// The test networks share the key encodings, the network of their descriptors has to be passed to ParseDescriptorForNetwork.
+// The zero value is not a valid descriptor, use one of the constructors or ParseDescriptor.
+type Descriptor struct {
+	scanSecKey  [32]byte
+	spendPubKey [33]byte
+	spendSecKey *[32]byte
+	network     Network
+}
+
+const (
+	// descriptorKeyVersion is the version of the spscan and spspend key encodings
+	descriptorKeyVersion = 0
+
+	scanKeyLength  = 32 + 33
+	spendKeyLength = 32 + 32
+)
+
+// NewWatchOnlyDescriptor creates a descriptor from the scan secret key and the spend public key
+func NewWatchOnlyDescriptor(scanSecKey [32]byte, spendPubKey [33]byte, network Network) (Descriptor, error) {
+	if _, err := NewPrivateKeySigner(scanSecKey); err != nil {
+		return Descriptor{}, err
+	}
+	if err := validatePublicKey(spendPubKey); err != nil {
+		return Descriptor{}, err
+	}
+
+	return Descriptor{
+		scanSecKey:  scanSecKey,
+		spendPubKey: spendPubKey,
+		network:     network,
+	}, nil
+}
+
+// NewDescriptor creates a descriptor which can spend from the scan and spend secret key
+func NewDescriptor(scanSecKey, spendSecKey [32]byte, network Network) (Descriptor, error) {
+	if _, err := NewPrivateKeySigner(spendSecKey); err != nil {
+		return Descriptor{}, err
+	}
+
+	descriptor, err := NewWatchOnlyDescriptor(scanSecKey, *PubKeyFromSecKey(&spendSecKey), network)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	descriptor.spendSecKey = &spendSecKey
+
+	return descriptor, nil
+}
+
+// NewDescriptorFromMaster creates the descriptor of an account, see DeriveKeysFromMasterAccount
+func NewDescriptorFromMaster(master *hdkeychain.ExtendedKey, network Network, account uint32) (Descriptor, error) {
+	scanSecKey, spendSecKey, err := DeriveKeysFromMasterAccount(master, network, account)
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	return NewDescriptor(scanSecKey, spendSecKey, network)
+}
+
+// ParseDescriptor decodes and validates a silent payment descriptor.
+// The network is derived from the hrp of the key encoding or the version of the WIF keys.
+// All test networks share the encodings, ErrNetworkAmbiguous is returned for them, use ParseDescriptorForNetwork.
+func ParseDescriptor(descriptor string) (Descriptor, error) {
+	return parseDescriptor(descriptor, nil)
+}
+
+// ParseDescriptorForNetwork decodes and validates a silent payment descriptor for network.
+// Returns ErrInvalidDescriptor if the keys are encoded for another network.
+func ParseDescriptorForNetwork(descriptor string, network Network) (Descriptor, error) {
+	return parseDescriptor(descriptor, &network)
+}
+
+// parseDescriptor parses a descriptor, network is nil if it should be derived from the keys
+func parseDescriptor(descriptor string, network *Network) (Descriptor, error) {
+	descriptor, checksum, hasChecksum := strings.Cut(descriptor, "#")
+	if hasChecksum {
+		expected, err := DescriptorChecksum(descriptor)
+		if err != nil {
+			return Descriptor{}, err
+		}
+		if checksum != expected {
+			return Descriptor{}, ErrDescriptorChecksum
+		}
+	}
+
+	args, ok := strings.CutPrefix(descriptor, "sp(")
+	if !ok {
+		return Descriptor{}, fmt.Errorf("%w: expected sp()", ErrInvalidDescriptor)
+	}
+	args, ok = strings.CutSuffix(args, ")")
+	if !ok {
+		return Descriptor{}, fmt.Errorf("%w: expected sp()", ErrInvalidDescriptor)
+	}
+
+	scanKey, spendKey, twoKeys := strings.Cut(args, ",")
+	if !twoKeys {
+		return decodeDescriptorKey(scanKey, network)
+	}
+
+	return parseDescriptorKeys(scanKey, spendKey, network)
+}
+
+// parseDescriptorKeys parses the two key form sp(SCAN,SPEND)
+func parseDescriptorKeys(scanKey, spendKey string, expectedNetwork *Network) (Descriptor, error) {
+	scanWIF, err := btcutil.DecodeWIF(scanKey)
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("%w: scan key: %v", ErrInvalidDescriptor, err)
+	}
+	mainnet := scanWIF.IsForNet(&chaincfg.MainNetParams)
+	network, err := descriptorNetwork(mainnet, expectedNetwork)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	scanSecKey := secKeyFromWIF(scanWIF)
+
+	// the spend key is either a public key or a private key
+	if spendWIF, err := btcutil.DecodeWIF(spendKey); err == nil {
+		if spendWIF.IsForNet(&chaincfg.MainNetParams) != mainnet {
+			return Descriptor{}, fmt.Errorf("%w: keys are for different networks", ErrInvalidDescriptor)
+		}
+		return NewDescriptor(scanSecKey, secKeyFromWIF(spendWIF), network)
+	}
+
+	// only compressed public keys are allowed
+	spendPubKey, err := hex.DecodeString(spendKey)
+	if err != nil || len(spendPubKey) != 33 {
+		return Descriptor{}, fmt.Errorf("%w: spend key", ErrInvalidDescriptor)
+	}
+
+	return NewWatchOnlyDescriptor(scanSecKey, [33]byte(spendPubKey), network)
+}
+
+// DecodeDescriptorKey decodes a spscan or spspend key encoding.
+// All test networks share the encodings, ErrNetworkAmbiguous is returned for them, use DecodeDescriptorKeyForNetwork.
+func DecodeDescriptorKey(key string) (Descriptor, error) {
+	return decodeDescriptorKey(key, nil)
+}
+
+// DecodeDescriptorKeyForNetwork decodes a spscan or spspend key encoding for network.
+// Returns ErrInvalidDescriptor if the key is encoded for another network.
+func DecodeDescriptorKeyForNetwork(key string, network Network) (Descriptor, error) {
+	return decodeDescriptorKey(key, &network)
+}
+
+// decodeDescriptorKey decodes a key encoding, expectedNetwork is nil if it should be derived from the hrp
+func decodeDescriptorKey(key string, expectedNetwork *Network) (Descriptor, error) {
+	hrp, data, err := bech32.DecodeNoLimit(key)
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("%w: %v", ErrInvalidDescriptor, err)
+	}
+	if len(data) == 0 || data[0] != descriptorKeyVersion {
+		return Descriptor{}, fmt.Errorf("%w: unknown key version", ErrInvalidDescriptor)
+	}
+
+	data, err = bech32.ConvertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return Descriptor{}, fmt.Errorf("%w: %v", ErrInvalidDescriptor, err)
+	}
+
+	var (
+		mainnet    bool
+		spendKey   bool
+		dataLength int
+	)
+	switch hrp {
+	case scanKeyHRP(Mainnet):
+		mainnet, dataLength = true, scanKeyLength
+	case scanKeyHRP(Testnet):
+		dataLength = scanKeyLength
+	case spendKeyHRP(Mainnet):
+		mainnet, dataLength, spendKey = true, spendKeyLength, true
+	case spendKeyHRP(Testnet):
+		dataLength, spendKey = spendKeyLength, true
+	default:
+		return Descriptor{}, fmt.Errorf("%w: unknown hrp %q", ErrInvalidDescriptor, hrp)
+	}
+
+	if len(data) != dataLength {
+		return Descriptor{}, fmt.Errorf("%w: invalid key length", ErrInvalidDescriptor)
+	}
+
+	network, err := descriptorNetwork(mainnet, expectedNetwork)
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	if spendKey {
+		return NewDescriptor([32]byte(data[:32]), [32]byte(data[32:]), network)
+	}
+	return NewWatchOnlyDescriptor([32]byte(data[:32]), [33]byte(data[32:]), network)
+}
+
+// descriptorNetwork returns the network of keys encoded for mainnet or the test networks.
+// The test networks share the encodings, expected has to be set for them.
+func descriptorNetwork(mainnet bool, expected *Network) (Network, error) {
+	switch {
+	case expected != nil && (*expected == Mainnet) != mainnet:
+		return 0, fmt.Errorf("%w: keys are not encoded for %s", ErrInvalidDescriptor, *expected)
+	case expected != nil:
+		return *expected, nil
+	case mainnet:
+		return Mainnet, nil
+	default:
+		return 0, ErrNetworkAmbiguous
+	}
+}
+
+// String returns the descriptor in the single key form with checksum
+func (d Descriptor) String() string {
+	key, err := d.EncodeKey()
+	if err != nil {
+		return ""
+	}
+
+	descriptor := "sp(" + key + ")"
+	checksum, err := DescriptorChecksum(descriptor)
+	if err != nil {
+		return ""
+	}
+
+	return descriptor + "#" + checksum
+}
+
+// EncodeKey returns the spspend encoding of the keys, or the spscan encoding for watch-only descriptors
+func (d Descriptor) EncodeKey() (string, error) {
+	data := make([]byte, 0, scanKeyLength)
+	data = append(data, d.scanSecKey[:]...)
+
+	hrp := scanKeyHRP(d.network)
+	if d.spendSecKey != nil {
+		hrp = spendKeyHRP(d.network)
+		data = append(data, d.spendSecKey[:]...)
+	} else {
+		data = append(data, d.spendPubKey[:]...)
+	}
+
+	convertedBits, err := bech32.ConvertBits(data, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	return bech32.EncodeM(hrp, append([]byte{descriptorKeyVersion}, convertedBits...))
+}
+
+// WatchOnly returns the descriptor without the spend secret key
+func (d Descriptor) WatchOnly() Descriptor {
+	d.spendSecKey = nil
+	return d
+}
+
+// IsWatchOnly returns true if the descriptor does not contain the spend secret key
+func (d Descriptor) IsWatchOnly() bool {
+	return d.spendSecKey == nil
+}
+
+func (d Descriptor) ScanSecKey() [32]byte {
+	return d.scanSecKey
+}
+
+func (d Descriptor) SpendPubKey() [33]byte {
+	return d.spendPubKey
+}
+
+// SpendSecKey returns the spend secret key, ok is false for watch-only descriptors
+func (d Descriptor) SpendSecKey() (spendSecKey [32]byte, ok bool) {
+	if d.spendSecKey == nil {
+		return [32]byte{}, false
+	}
+	return *d.spendSecKey, true
+}
+
+func (d Descriptor) Network() Network {
+	return d.network
+}
+
+// WatchOnlyKey creates a WatchOnlyKey from the descriptor, see NewWatchOnlyKey for birthday
+func (d Descriptor) WatchOnlyKey(birthday uint32) (*WatchOnlyKey, error) {
+	return NewWatchOnlyKey(d.scanSecKey, d.spendPubKey, birthday, d.network)
+}
+
+// MarshalText implements encoding.TextMarshaler, it is also used for JSON.
+// The text form is that of String, it does not record which test network a descriptor of a test network belongs to.
+func (d Descriptor) MarshalText() ([]byte, error) {
+	if err := validatePublicKey(d.spendPubKey); err != nil {
+		return nil, err
+	}
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, it is also used for JSON.
+// The network is derived as in ParseDescriptor. All test networks share the key encodings,
+// for them the network of d is kept if it is a test network and Testnet is used otherwise.
+func (d *Descriptor) UnmarshalText(text []byte) error {
+	descriptor, err := ParseDescriptor(string(text))
+	if errors.Is(err, ErrNetworkAmbiguous) {
+		network := d.network
+		if network == Mainnet {
+			network = Testnet
+		}
+		descriptor, err = ParseDescriptorForNetwork(string(text), network)
+	}
+	if err != nil {
+		return err
+	}
+	*d = descriptor
+	return nil
+}
+
+// scanKeyHRP returns the hrp of the spscan encoding, all test networks share one hrp
+func scanKeyHRP(network Network) string {
+	if network == Mainnet {
+		return "spscan"
+	}
+	return "tspscan"
+}
+
+// spendKeyHRP returns the hrp of the spspend encoding, all test networks share one hrp
+func spendKeyHRP(network Network) string {
+	if network == Mainnet {
+		return "spspend"
+	}
+	return "tspspend"
+}
+
+func secKeyFromWIF(wif *btcutil.WIF) [32]byte {
+	var secKey [32]byte
+	wif.PrivKey.Key.PutBytes(&secKey)
+	return secKey
+}
+
+const (
+	descriptorInputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+// DescriptorChecksum computes the BIP380 checksum of a descriptor without checksum
+func DescriptorChecksum(descriptor string) (string, error) {
+	var (
+		c        uint64 = 1
+		class    uint64
+		numClass int
+	)
+	for _, char := range descriptor {
+		pos := strings.IndexRune(descriptorInputCharset, char)
+		if pos < 0 {
+			return "", fmt.Errorf("%w: invalid character %q", ErrInvalidDescriptor, char)
+		}
+
+		// emit a symbol for the position inside the group, for every character
+		c = descriptorPolyMod(c, uint64(pos)&31)
+		// accumulate the group numbers
+		class = class*3 + uint64(pos)>>5
+		numClass++
+		if numClass == 3 {
+			// emit an extra symbol representing the group numbers, for every 3 characters
+			c = descriptorPolyMod(c, class)
+			class = 0
+			numClass = 0
+		}
+	}
+	if numClass > 0 {
+		c = descriptorPolyMod(c, class)
+	}
+	for i := 0; i < 8; i++ {
+		c = descriptorPolyMod(c, 0)
+	}
+	c ^= 1
+
+	checksum := make([]byte, 8)
+	for i := range checksum {
+		checksum[i] = descriptorChecksumCharset[(c>>(5*(7-i)))&31]
+	}
+
+	return string(checksum), nil
+}
+
+func descriptorPolyMod(c, val uint64) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ val
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
@@ -0,0 +1,230 @@
+package bip352
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/require"
+	"github.com/tyler-smith/go-bip39"
+)
+
+func TestDescriptorChecksum(t *testing.T) {
+	// test vector from BIP380
+	checksum, err := DescriptorChecksum("raw(deadbeef)")
+	require.NoError(t, err)
+	require.Equal(t, "89f8spxm", checksum)
+
+	_, err = DescriptorChecksum("sp(é)")
+	require.ErrorIs(t, err, ErrInvalidDescriptor)
+}
+
+func TestDescriptorRoundTrip(t *testing.T) {
+	scanSecKey, spendSecKey := testKeys()
+	spendPubKey := *PubKeyFromSecKey(&spendSecKey)
+
+	for _, network := range []Network{Mainnet, Testnet, Signet, Regtest} {
+		descriptor, err := NewDescriptor(scanSecKey, spendSecKey, network)
+		require.NoError(t, err)
+		require.False(t, descriptor.IsWatchOnly())
+
+		prefix := "sp(spspend1q"
+		if network != Mainnet {
+			prefix = "sp(tspspend1q"
+		}
+		require.True(t, strings.HasPrefix(descriptor.String(), prefix), descriptor.String())
+
+		parsed, err := ParseDescriptorForNetwork(descriptor.String(), network)
+		require.NoError(t, err)
+		require.Equal(t, descriptor, parsed)
+
+		// the test networks share the encoding
+		_, err = ParseDescriptor(descriptor.String())
+		if network == Mainnet {
+			require.NoError(t, err)
+			_, err = ParseDescriptorForNetwork(descriptor.String(), Testnet)
+			require.ErrorIs(t, err, ErrInvalidDescriptor)
+		} else {
+			require.ErrorIs(t, err, ErrNetworkAmbiguous)
+			_, err = ParseDescriptorForNetwork(descriptor.String(), Mainnet)
+			require.ErrorIs(t, err, ErrInvalidDescriptor)
+		}
+
+		spendKey, ok := parsed.SpendSecKey()
+		require.True(t, ok)
+		require.Equal(t, spendSecKey, spendKey)
+		require.Equal(t, spendPubKey, parsed.SpendPubKey())
+		require.Equal(t, network, parsed.Network())
+
+		// the checksum is optional
+		withoutChecksum, _, _ := strings.Cut(descriptor.String(), "#")
+		parsed, err = ParseDescriptorForNetwork(withoutChecksum, network)
+		require.NoError(t, err)
+		require.Equal(t, descriptor, parsed)
+
+		watchOnly := descriptor.WatchOnly()
+		require.True(t, watchOnly.IsWatchOnly())
+		require.False(t, descriptor.IsWatchOnly())
+
+		prefix = "sp(spscan1q"
+		if network != Mainnet {
+			prefix = "sp(tspscan1q"
+		}
+		require.True(t, strings.HasPrefix(watchOnly.String(), prefix), watchOnly.String())
+
+		parsed, err = ParseDescriptorForNetwork(watchOnly.String(), network)
+		require.NoError(t, err)
+		require.Equal(t, watchOnly, parsed)
+		_, ok = parsed.SpendSecKey()
+		require.False(t, ok)
+		require.Equal(t, scanSecKey, parsed.ScanSecKey())
+		require.Equal(t, spendPubKey, parsed.SpendPubKey())
+
+		key, err := parsed.WatchOnlyKey(10)
+		require.NoError(t, err)
+		keyDescriptor, err := key.Descriptor()
+		require.NoError(t, err)
+		require.Equal(t, watchOnly.String(), keyDescriptor.String())
+
+		// the network is kept, regtest addresses have their own hrp
+		address, err := key.Address()
+		require.NoError(t, err)
+		require.Equal(t, network, address.Network())
+		require.True(t, strings.HasPrefix(address.String(), network.HRP()+"1q"), address.String())
+	}
+}
+
+func TestParseDescriptorTwoKeys(t *testing.T) {
+	scanSecKey, spendSecKey := testKeys()
+	spendPubKey := *PubKeyFromSecKey(&spendSecKey)
+
+	scanWIF, err := btcutil.NewWIF(secKeyFromBytes(scanSecKey), &chaincfg.TestNet3Params, true)
+	require.NoError(t, err)
+	spendWIF, err := btcutil.NewWIF(secKeyFromBytes(spendSecKey), &chaincfg.TestNet3Params, true)
+	require.NoError(t, err)
+	mainnetSpendWIF, err := btcutil.NewWIF(secKeyFromBytes(spendSecKey), &chaincfg.MainNetParams, true)
+	require.NoError(t, err)
+
+	expected, err := NewDescriptor(scanSecKey, spendSecKey, Testnet)
+	require.NoError(t, err)
+
+	descriptor, err := ParseDescriptorForNetwork("sp("+scanWIF.String()+","+spendWIF.String()+")", Testnet)
+	require.NoError(t, err)
+	require.Equal(t, expected, descriptor)
+
+	// WIF keys of the test networks share the version
+	_, err = ParseDescriptor("sp(" + scanWIF.String() + "," + spendWIF.String() + ")")
+	require.ErrorIs(t, err, ErrNetworkAmbiguous)
+	_, err = ParseDescriptorForNetwork("sp("+scanWIF.String()+","+spendWIF.String()+")", Mainnet)
+	require.ErrorIs(t, err, ErrInvalidDescriptor)
+
+	checksum, err := DescriptorChecksum("sp(" + scanWIF.String() + "," + hex.EncodeToString(spendPubKey[:]) + ")")
+	require.NoError(t, err)
+	descriptor, err = ParseDescriptorForNetwork("sp("+scanWIF.String()+","+hex.EncodeToString(spendPubKey[:])+")#"+checksum, Testnet)
+	require.NoError(t, err)
+	require.Equal(t, expected.WatchOnly(), descriptor)
+
+	_, err = ParseDescriptorForNetwork("sp("+scanWIF.String()+","+mainnetSpendWIF.String()+")", Testnet)
+	require.ErrorIs(t, err, ErrInvalidDescriptor)
+
+	uncompressed := secKeyFromBytes(spendSecKey).PubKey().SerializeUncompressed()
+	_, err = ParseDescriptorForNetwork("sp("+scanWIF.String()+","+hex.EncodeToString(uncompressed)+")", Testnet)
+	require.ErrorIs(t, err, ErrInvalidDescriptor)
+}
+
+func TestParseDescriptorInvalid(t *testing.T) {
+	scanSecKey, spendSecKey := testKeys()
+
+	descriptor, err := NewDescriptor(scanSecKey, spendSecKey, Mainnet)
+	require.NoError(t, err)
+	valid := descriptor.String()
+
+	_, err = ParseDescriptor(valid[:len(valid)-1] + "q")
+	require.ErrorIs(t, err, ErrDescriptorChecksum)
+
+	_, err = ParseDescriptor(strings.Replace(valid, "sp(", "tr(", 1))
+	require.ErrorIs(t, err, ErrDescriptorChecksum)
+
+	key, err := descriptor.EncodeKey()
+	require.NoError(t, err)
+	_, err = ParseDescriptor("tr(" + key + ")")
+	require.ErrorIs(t, err, ErrInvalidDescriptor)
+
+	// an address is not a key encoding
+	address, err := CreateAddress(PubKeyFromSecKey(&scanSecKey), PubKeyFromSecKey(&spendSecKey), Mainnet, 0)
+	require.NoError(t, err)
+	_, err = ParseDescriptor("sp(" + address + ")")
+	require.ErrorIs(t, err, ErrInvalidDescriptor)
+
+	_, err = NewDescriptor([32]byte{}, spendSecKey, Mainnet)
+	require.ErrorIs(t, err, ErrInvalidSecretKey)
+	_, err = NewWatchOnlyDescriptor(scanSecKey, [33]byte{0x02}, Mainnet)
+	require.ErrorIs(t, err, ErrInvalidPublicKey)
+}
+
+func TestDescriptorJSON(t *testing.T) {
+	scanSecKey, spendSecKey := testKeys()
+
+	descriptor, err := NewWatchOnlyDescriptor(scanSecKey, *PubKeyFromSecKey(&spendSecKey), Mainnet)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(descriptor)
+	require.NoError(t, err)
+
+	var decoded Descriptor
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, descriptor, decoded)
+
+	// the test networks share the encoding, the network of the target is kept
+	for _, network := range []Network{Testnet, Testnet4, Signet, Regtest} {
+		descriptor, err = NewWatchOnlyDescriptor(scanSecKey, *PubKeyFromSecKey(&spendSecKey), network)
+		require.NoError(t, err)
+		data, err = json.Marshal(descriptor)
+		require.NoError(t, err)
+
+		decoded = Descriptor{network: network}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.Equal(t, descriptor, decoded)
+
+		// without a test network in the target testnet is used
+		decoded = Descriptor{}
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.Equal(t, Testnet, decoded.Network())
+		require.Equal(t, descriptor.String(), decoded.String())
+	}
+
+	_, err = json.Marshal(Descriptor{})
+	require.Error(t, err)
+}
+
+func TestNewDescriptorFromMaster(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	master, err := hdkeychain.NewMaster(bip39.NewSeed(mnemonic, ""), &chaincfg.MainNetParams)
+	require.NoError(t, err)
+
+	scanSecKey, spendSecKey, err := DeriveKeysFromMaster(master, Mainnet)
+	require.NoError(t, err)
+
+	descriptor, err := NewDescriptorFromMaster(master, Mainnet, 0)
+	require.NoError(t, err)
+	require.Equal(t, scanSecKey, descriptor.ScanSecKey())
+	spendKey, _ := descriptor.SpendSecKey()
+	require.Equal(t, spendSecKey, spendKey)
+
+	account1, err := NewDescriptorFromMaster(master, Mainnet, 1)
+	require.NoError(t, err)
+	require.NotEqual(t, descriptor.ScanSecKey(), account1.ScanSecKey())
+
+	_, err = NewDescriptorFromMaster(master, Mainnet, hdkeychain.HardenedKeyStart)
+	require.ErrorIs(t, err, ErrInvalidAccount)
+}
+
+func secKeyFromBytes(secKey [32]byte) *btcec.PrivateKey {
+	privKey, _ := btcec.PrivKeyFromBytes(secKey[:])
+	return privKey
+}
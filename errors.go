@@ -9,9 +9,76 @@ var (
 
 	AddressHRPError = errors.New("hrp did not match network")
 
+	ErrUnknownNetwork = errors.New("unknown network")
+
+	// ErrNetworkAmbiguous is returned for the hrp shared by testnet, testnet4 and signet
+	ErrNetworkAmbiguous = errors.New("hrp is shared by several networks")
+
 	DecodingLimitExceeded = errors.New("exceeds BIP0352 recommended 1023 character limit")
 
 	ErrVinsEmpty = errors.New("vins were empty")
 
 	ErrNoEligibleVins = errors.New("no eligible vins")
+
+	ErrNoTaprootOutputs = errors.New("transaction has no taproot outputs")
+
+	// ErrSegwitVersionUnsupported is returned for transactions spending witness versions above 1.
+	// BIP352 reserves those for future upgrades, such transactions must not contain silent payments.
+	ErrSegwitVersionUnsupported = errors.New("transaction spends an output with segwit version above 1")
+
+	ErrInvalidAddressLength = errors.New("invalid silent payment address length")
+
+	ErrInvalidPublicKey = errors.New("invalid public key")
+
+	ErrInvalidWitness = errors.New("invalid witness")
+
+	ErrInvalidSecretKey = errors.New("invalid secret key")
+
+	// ErrSecretKeyMissing is returned if an eligible input has neither a secret key nor a signer.
+	// The receiver includes every eligible input in A_sum, the outputs could not be found without it.
+	ErrSecretKeyMissing = errors.New("secret key missing for eligible input")
+
+	ErrPrevOutMissing = errors.New("prevout missing for input")
+
+	// ErrTweak is returned if a scalar operation overflows or results in zero
+	ErrTweak = errors.New("tweak operation failed")
+
+	// ErrInvalidAccount is returned for accounts that can not be hardened in a derivation path
+	ErrInvalidAccount = errors.New("invalid account index")
+
+	ErrInvalidDescriptor = errors.New("invalid silent payment descriptor")
+
+	ErrDescriptorChecksum = errors.New("invalid descriptor checksum")
+
+	ErrDLEQProofGeneration = errors.New("failed to generate dleq proof")
+
+	// ErrTooManyGroupOutputs is returned if more than 2323 outputs go to the same scan key
+	ErrTooManyGroupOutputs = errors.New("too many outputs for one recipient group")
+
+	// ErrOutputMissing is returned if the output of a recipient has not been created
+	ErrOutputMissing = errors.New("recipient has no output")
+
+	ErrInvalidAmount = errors.New("invalid amount")
+
+	ErrUnknownOutputOrder = errors.New("unknown output order")
+
+	ErrNoRecipients = errors.New("no recipients")
+
+	ErrInsufficientFunds = errors.New("insufficient funds")
+
+	ErrECDHShareMissing = errors.New("no ecdh share for recipient scan key")
+
+	// ErrOutputKeyMismatch is returned if the spend key and the tweak do not produce the output key
+	ErrOutputKeyMismatch = errors.New("spend key does not belong to the output")
+
+	ErrLabelMissing = errors.New("label missing")
+
+	// ErrLabelMismatch is returned if stored labels were not created with the keys of the LabelManager
+	ErrLabelMismatch = errors.New("label does not belong to the keys of the label manager")
+
+	// ErrAddressVersionIncompatible is returned for version 31 which is reserved for a backwards incompatible change
+	ErrAddressVersionIncompatible = errors.New("silent payment address version is not backwards compatible")
+
+	// ErrAddressVersionInvalid is returned for versions that can not be encoded in an address
+	ErrAddressVersionInvalid = errors.New("invalid silent payment address version")
 )
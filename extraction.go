@@ -2,6 +2,8 @@ package bip352
 
 import (
 	"bytes"
+
+	"github.com/btcsuite/btcd/txscript"
 )
 
 // NumsH = 0x50929b74c1a04954b78b4b6035e97a5e078a5a0f28ec96d547bfee9ace803ac0
@@ -10,7 +12,12 @@ var NumsH = []byte{80, 146, 155, 116, 193, 160, 73, 84, 183, 139, 75, 96, 53, 23
 // ExtractEligibleVins
 // returns a slice of vins which are eligible as inputs for the shared derivation
 // NOTE: Returns a deep copy of the vins this will also set the taproot bool flag in the vins of the new returned slice
+// Returns ErrSegwitVersionUnsupported if any of the vins spends a segwit version above 1, the transaction can not be used for silent payments then.
 func ExtractEligibleVins(vins []*Vin) ([]*Vin, error) {
+	if spendsSegwitVersionAboveOne(vins) {
+		return nil, ErrSegwitVersionUnsupported
+	}
+
 	var eligibleVins []*Vin
 
 	for _, vin := range vins {
@@ -33,7 +40,8 @@ func ExtractEligibleVins(vins []*Vin) ([]*Vin, error) {
 
 // ExtractPubKey
 // this routine is not optimised yet and might not be able to parse all edge cases.
-// NOTE: Does not throw any errors, check the utxo type or the byte slice to see whether a public key could be extracted
+// NOTE: Does not throw any errors, check the utxo type or the byte slice to see whether a public key could be extracted.
+// Malformed witnesses or scripts result in Unknown.
 func ExtractPubKey(vin *Vin) ([]byte, TypeUTXO) {
 	var pubKey []byte
 	var utxoType = Unknown
@@ -45,7 +53,7 @@ func ExtractPubKey(vin *Vin) ([]byte, TypeUTXO) {
 		}
 	} else if IsP2WPKH(vin.ScriptPubKey) {
 		// last element in the witness data is public key; skip uncompressed
-		if len(vin.Witness[len(vin.Witness)-1]) == 33 {
+		if len(vin.Witness) > 0 && len(vin.Witness[len(vin.Witness)-1]) == 33 {
 			pubKey = vin.Witness[len(vin.Witness)-1]
 			utxoType = P2WPKH
 		}
@@ -58,7 +66,7 @@ func ExtractPubKey(vin *Vin) ([]byte, TypeUTXO) {
 		// P2SH-P2WPKH which is seen as a p2sh
 		if len(vin.ScriptSig) == 23 {
 			if bytes.Equal(vin.ScriptSig[:3], []byte{0x16, 0x00, 0x14}) {
-				if len(vin.Witness[len(vin.Witness)-1]) == 33 {
+				if len(vin.Witness) > 0 && len(vin.Witness[len(vin.Witness)-1]) == 33 {
 					pubKey = vin.Witness[len(vin.Witness)-1]
 					utxoType = P2SH
 				}
@@ -71,6 +79,9 @@ func ExtractPubKey(vin *Vin) ([]byte, TypeUTXO) {
 
 // extractPublicKey tries to find a public key within the given scriptSig.
 func extractFromP2PKH(vin *Vin) []byte {
+	if !IsP2PKH(vin.ScriptPubKey) {
+		return nil
+	}
 
 	spkHash := vin.ScriptPubKey[3:23] // Skip op_codes and grab the hash
 
@@ -91,8 +102,8 @@ func extractPubKeyFromP2TR(vin *Vin) []byte {
 	witnessStack := vin.Witness
 
 	if len(witnessStack) >= 1 {
-		// Remove annex if present
-		if len(witnessStack) > 1 && bytes.Equal(witnessStack[len(witnessStack)-1], []byte{0x50}) {
+		// Remove annex if present, the annex is the last item and starts with 0x50
+		if annex := witnessStack[len(witnessStack)-1]; len(witnessStack) > 1 && len(annex) > 0 && annex[0] == 0x50 {
 			witnessStack = witnessStack[:len(witnessStack)-1]
 		}
 
@@ -153,3 +164,14 @@ func IsP2PKH(spk []byte) bool {
 	// OP_DUP OP_HASH160 OP_PUSHBYTES_20 <20 bytes> OP_EQUALVERIFY OP_CHECKSIG
 	return spk[0] == 0x76 && spk[1] == 0xA9 && spk[2] == 0x14 && spk[len(spk)-2] == 0x88 && spk[len(spk)-1] == 0xAC
 }
+
+// spendsSegwitVersionAboveOne checks whether any of the vins spends a witness program with version 2 to 16
+func spendsSegwitVersionAboveOne(vins []*Vin) bool {
+	for _, vin := range vins {
+		version, _, err := txscript.ExtractWitnessProgramInfo(vin.ScriptPubKey)
+		if err == nil && version > 1 {
+			return true
+		}
+	}
+	return false
+}
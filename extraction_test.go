@@ -0,0 +1,156 @@
+package bip352
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/setavenger/blindbit-lib/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegwitVersionAboveOne(t *testing.T) {
+	for _, testCase := range []struct {
+		name         string
+		scriptPubKey []byte
+		unsupported  bool
+	}{
+		{"p2wpkh", append([]byte{txscript.OP_0, 0x14}, make([]byte, 20)...), false},
+		{"p2tr", append([]byte{txscript.OP_1, 0x20}, make([]byte, 32)...), false},
+		{"p2a", []byte{txscript.OP_1, 0x02, 0x4e, 0x73}, false},
+		{"v2", append([]byte{txscript.OP_2, 0x20}, make([]byte, 32)...), true},
+		{"v16", []byte{txscript.OP_16, 0x02, 0x00, 0x00}, true},
+		// not a witness program, the push is too short
+		{"v2 invalid program", []byte{txscript.OP_2, 0x01, 0x00}, false},
+		{"p2pkh", append(append([]byte{txscript.OP_DUP, txscript.OP_HASH160, 0x14}, make([]byte, 20)...), txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG), false},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			vins := []*Vin{{ScriptPubKey: testCase.scriptPubKey, Witness: [][]byte{make([]byte, 33)}}}
+			require.Equal(t, testCase.unsupported, spendsSegwitVersionAboveOne(vins))
+
+			_, err := ExtractEligibleVins(vins)
+			if testCase.unsupported {
+				require.ErrorIs(t, err, ErrSegwitVersionUnsupported)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSegwitVersionAboveOneTransaction(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	testCase := caseData[0].Receiving[0]
+	secKeyScan, secKeySpend := testVectorKeys(t, testCase.Given.KeyMaterial.ScanPrivKey, testCase.Given.KeyMaterial.SpendPrivKey)
+
+	// the outputs of the vector are found without the additional input
+	tx, fetcher := txFromTestVins(t, testCase.Given.Vin, testCase.Given.Outputs)
+	outpoint := wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 0}
+	tx.AddTxIn(wire.NewTxIn(&outpoint, nil, wire.TxWitness{{0x01}}))
+	fetcher.AddPrevOut(outpoint, wire.NewTxOut(1000, append([]byte{txscript.OP_2, 0x20}, make([]byte, 32)...)))
+
+	_, err = ReceiverScanTx(secKeyScan, PubKeyFromSecKey(&secKeySpend), nil, tx, fetcher)
+	require.ErrorIs(t, err, ErrSegwitVersionUnsupported)
+
+	// the sender must not create outputs either
+	sendCase := caseData[0].Sending[0]
+	secretKeys := make(map[wire.OutPoint][32]byte)
+	for i, vin := range sendCase.Given.Vin {
+		secKey, err := hex.DecodeString(vin.PrivateKey)
+		require.NoError(t, err)
+		secretKeys[tx.TxIn[i].PreviousOutPoint] = utils.ConvertToFixedLength32(secKey)
+	}
+	recipients := []*Recipient{{SilentPaymentAddress: sendCase.Given.Recipients[0]}}
+	err = SenderCreateOutputsFromTx(recipients, tx, fetcher, secretKeys, Mainnet)
+	require.ErrorIs(t, err, ErrSegwitVersionUnsupported)
+
+	vins, err := VinsFromTx(tx, fetcher)
+	require.NoError(t, err)
+	for i := range sendCase.Given.Vin {
+		secretKey := secretKeys[tx.TxIn[i].PreviousOutPoint]
+		vins[i].SecretKey = &secretKey
+	}
+	err = SenderCreateOutputs(recipients, vins, Mainnet, true)
+	require.ErrorIs(t, err, ErrSegwitVersionUnsupported)
+}
+
+func TestExtractPubKeyMalformed(t *testing.T) {
+	p2wpkh := append([]byte{txscript.OP_0, 0x14}, make([]byte, 20)...)
+	p2sh := append(append([]byte{txscript.OP_HASH160, 0x14}, make([]byte, 20)...), txscript.OP_EQUAL)
+	p2shP2wpkhScriptSig := append([]byte{0x16, 0x00, 0x14}, make([]byte, 20)...)
+	p2tr := append([]byte{txscript.OP_1, 0x20}, make([]byte, 32)...)
+
+	for _, testCase := range []struct {
+		name string
+		vin  *Vin
+	}{
+		{"p2wpkh without witness", &Vin{ScriptPubKey: p2wpkh}},
+		{"p2sh-p2wpkh without witness", &Vin{ScriptPubKey: p2sh, ScriptSig: p2shP2wpkhScriptSig}},
+		{"p2tr without witness", &Vin{ScriptPubKey: p2tr}},
+		{"p2pkh without scriptSig", &Vin{ScriptPubKey: append(append([]byte{txscript.OP_DUP, txscript.OP_HASH160, 0x14}, make([]byte, 20)...), txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG)}},
+		{"empty", &Vin{}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			pubKey, utxoType := ExtractPubKey(testCase.vin)
+			require.Nil(t, pubKey)
+			require.Equal(t, Unknown, utxoType)
+		})
+	}
+
+	// malformed p2pkh scriptPubKeys are not parsed
+	require.Nil(t, extractFromP2PKH(&Vin{ScriptPubKey: []byte{txscript.OP_DUP}, ScriptSig: make([]byte, 33)}))
+
+	// the annex is removed before checking for a script-path spend
+	annexVin := &Vin{ScriptPubKey: p2tr, Witness: [][]byte{make([]byte, 64), {0x50, 0x01, 0x02}}}
+	pubKey, utxoType := ExtractPubKey(annexVin)
+	require.Equal(t, P2TR, utxoType)
+	require.Equal(t, p2tr[2:], pubKey)
+}
+
+// receivingTestVins returns the inputs of all receiving test vectors as seeds for the fuzz targets
+func receivingTestVins(f *testing.F) []VinReceiveTestCase {
+	caseData, err := LoadFullCaseData(f)
+	require.NoError(f, err)
+
+	var vins []VinReceiveTestCase
+	for _, cases := range caseData {
+		for _, testCase := range cases.Receiving {
+			vins = append(vins, testCase.Given.Vin...)
+		}
+	}
+	return vins
+}
+
+func FuzzExtractPubKey(f *testing.F) {
+	for _, vin := range receivingTestVins(f) {
+		scriptPubKey, _ := hex.DecodeString(vin.Prevout.ScriptPubKey.Hex)
+		scriptSig, _ := hex.DecodeString(vin.ScriptSig)
+		witness, _ := hex.DecodeString(vin.Txinwitness)
+		f.Add(scriptPubKey, scriptSig, witness)
+	}
+
+	f.Fuzz(func(t *testing.T, scriptPubKey, scriptSig, witness []byte) {
+		witnessItems, err := ParseWitnessScript(witness)
+		if err != nil {
+			// use the raw bytes as a single item to reach the witness checks with arbitrary data
+			witnessItems = [][]byte{witness}
+		}
+
+		vin := &Vin{ScriptPubKey: scriptPubKey, ScriptSig: scriptSig, Witness: witnessItems}
+		pubKey, utxoType := ExtractPubKey(vin)
+		switch utxoType {
+		case Unknown:
+			require.Nil(t, pubKey)
+		case P2TR:
+			require.Len(t, pubKey, 32)
+		default:
+			require.Len(t, pubKey, 33)
+		}
+
+		_, _ = ExtractEligibleVins([]*Vin{vin})
+	})
+}
@@ -0,0 +1,73 @@
+package bip352
+
+import (
+	"github.com/btcsuite/btcd/btcutil/gcs"
+	"github.com/btcsuite/btcd/btcutil/gcs/builder"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// FilterCandidates computes the taproot scriptPubKeys of the first output (k=0) for every tweak.
+// For every label the labelled output B_m + t_0·G is included as well.
+// If none of the candidates is in a block, the block does not contain outputs of the receiver.
+// tweaks: A_sum·input_hash of the transactions in a block, see ComputeBlockTweaks
+func FilterCandidates(
+	scanSecKey [32]byte,
+	receiverSpendPubKey *[33]byte,
+	labels []*Label,
+	tweaks [][33]byte,
+) ([][]byte, error) {
+	spendPubKeys := [][33]byte{*receiverSpendPubKey}
+	for _, label := range labels {
+		labelledSpendPubKey, err := CreateLabelledSpendPubKey(receiverSpendPubKey, &label.PubKey)
+		if err != nil {
+			return nil, err
+		}
+		spendPubKeys = append(spendPubKeys, labelledSpendPubKey)
+	}
+
+	candidates := make([][]byte, 0, len(tweaks)*len(spendPubKeys))
+	for _, tweak := range tweaks {
+		sharedSecret, err := CreateSharedSecret(&tweak, &scanSecKey, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, spendPubKey := range spendPubKeys {
+			outputPubKey, err := CreateOutputPubKey(*sharedSecret, spendPubKey, 0)
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, P2TRScript(outputPubKey))
+		}
+	}
+
+	return candidates, nil
+}
+
+// MatchFilter checks whether any of the candidates is in the BIP158 basic filter of a block.
+// A match can be a false positive, the block has to be scanned then.
+func MatchFilter(filter *gcs.Filter, blockHash *chainhash.Hash, candidates [][]byte) (bool, error) {
+	if len(candidates) == 0 || filter.N() == 0 {
+		return false, nil
+	}
+
+	return filter.MatchAny(builder.DeriveKey(blockHash), candidates)
+}
+
+// MatchBlockFilter checks whether a block might contain outputs of the receiver before it is downloaded.
+// See FilterCandidates and MatchFilter.
+func MatchBlockFilter(
+	scanSecKey [32]byte,
+	receiverSpendPubKey *[33]byte,
+	labels []*Label,
+	tweaks [][33]byte,
+	filter *gcs.Filter,
+	blockHash *chainhash.Hash,
+) (bool, error) {
+	candidates, err := FilterCandidates(scanSecKey, receiverSpendPubKey, labels, tweaks)
+	if err != nil {
+		return false, err
+	}
+
+	return MatchFilter(filter, blockHash, candidates)
+}
@@ -0,0 +1,84 @@
+package bip352
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/gcs/builder"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchBlockFilter(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	blockHash := chainhash.HashH([]byte("block"))
+	unrelated := sha256.Sum256([]byte("unrelated"))
+
+	for _, cases := range caseData {
+		for _, testCase := range cases.Receiving {
+			secKeyScan, secKeySpend := testVectorKeys(t, testCase.Given.KeyMaterial.ScanPrivKey, testCase.Given.KeyMaterial.SpendPrivKey)
+			spendPubKey := PubKeyFromSecKey(&secKeySpend)
+
+			var labels []*Label
+			for _, m := range testCase.Given.Labels {
+				label, err := CreateLabel(&secKeyScan, m)
+				require.NoError(t, err)
+				labels = append(labels, &label)
+			}
+
+			tx, fetcher := txFromTestVins(t, testCase.Given.Vin, testCase.Given.Outputs)
+			tweak, err := ComputeTweak(tx, fetcher)
+			if err != nil {
+				continue
+			}
+
+			// the block filter contains the outputs of the transaction and an unrelated script
+			var scripts [][]byte
+			for _, txOut := range tx.TxOut {
+				scripts = append(scripts, txOut.PkScript)
+			}
+			scripts = append(scripts, append([]byte{0x51, 0x20}, unrelated[:]...))
+			filter, err := builder.WithKeyHash(&blockHash).AddEntries(scripts).Build()
+			require.NoError(t, err)
+
+			// a second tweak from another transaction in the block does not hurt
+			otherTweak := *PubKeyFromSecKey(&unrelated)
+
+			match, err := MatchBlockFilter(secKeyScan, spendPubKey, labels, [][33]byte{otherTweak, *tweak}, filter, &blockHash)
+			require.NoError(t, err, cases.Comment)
+			require.Equal(t, len(testCase.Expected.Outputs) > 0, match, cases.Comment)
+		}
+	}
+}
+
+func TestFilterCandidates(t *testing.T) {
+	scanSecKey, spendSecKey := testKeys()
+	spendPubKey := PubKeyFromSecKey(&spendSecKey)
+	tweakSecKey := sha256.Sum256([]byte("tweak"))
+	tweak := *PubKeyFromSecKey(&tweakSecKey)
+
+	label, err := CreateLabel(&scanSecKey, 1)
+	require.NoError(t, err)
+
+	candidates, err := FilterCandidates(scanSecKey, spendPubKey, []*Label{&label}, [][33]byte{tweak, tweak})
+	require.NoError(t, err)
+	require.Len(t, candidates, 4)
+	for _, candidate := range candidates {
+		require.True(t, IsP2TR(candidate))
+	}
+	require.Equal(t, candidates[0], candidates[2])
+	require.NotEqual(t, candidates[0], candidates[1])
+
+	_, err = FilterCandidates(scanSecKey, spendPubKey, nil, [][33]byte{{0x02}})
+	require.Error(t, err)
+
+	// an empty filter never matches
+	blockHash := chainhash.HashH([]byte("block"))
+	filter, err := builder.WithKeyHash(&blockHash).Build()
+	require.NoError(t, err)
+	match, err := MatchFilter(filter, &blockHash, candidates)
+	require.NoError(t, err)
+	require.False(t, match)
+}
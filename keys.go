@@ -2,15 +2,14 @@ package bip352
 
 import (
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
-	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/setavenger/blindbit-lib/utils"
 	"github.com/tyler-smith/go-bip39"
 )
 
-// KeysFromMnemonic computes the scan and spend secret keys based on a mnemonic, a seedphrase (optional, leave as empty string if not needed) and chain
+// KeysFromMnemonic computes the scan and spend secret keys based on a mnemonic, a seedphrase (optional, leave as empty string if not needed) and network
 func KeysFromMnemonic(
 	mnemonic, seedPassphrase string,
-	mainnet bool,
+	network Network,
 ) (
 	scanSecret, spendSecret [32]byte,
 	err error,
@@ -21,67 +20,71 @@ func KeysFromMnemonic(
 		return
 	}
 
-	chainParams := chaincfg.MainNetParams
-	if !mainnet {
-		chainParams = chaincfg.SigNetParams
-	}
-
-	master, err := hdkeychain.NewMaster(seed, &chainParams)
+	master, err := hdkeychain.NewMaster(seed, network.Params())
 	if err != nil {
 		return
 	}
 
-	return DeriveKeysFromMaster(master, mainnet)
+	return DeriveKeysFromMaster(master, network)
 }
 
+// DeriveKeysFromMaster derives the scan and spend secret keys of account 0, see DeriveKeysFromMasterAccount
 func DeriveKeysFromMaster(
 	master *hdkeychain.ExtendedKey,
-	mainnet bool,
+	network Network,
+) (
+	scanSecret, spendSecret [32]byte,
+	err error,
+) {
+	return DeriveKeysFromMasterAccount(master, network, 0)
+}
+
+// DeriveKeysFromMasterAccount derives the scan and spend secret keys of an account.
+// account is hardened in the path and has to be below hdkeychain.HardenedKeyStart.
+func DeriveKeysFromMasterAccount(
+	master *hdkeychain.ExtendedKey,
+	network Network,
+	account uint32,
 ) (
 	scanSecret, spendSecret [32]byte,
 	err error,
 ) {
 	/*
-		ScanDerivationPath = "m/352'/0'/0'/1'/0";
-		SpendDerivationPath = "m/352'/0'/0'/0'/0";
+		ScanDerivationPath = "m/352'/coin_type'/account'/1'/0";
+		SpendDerivationPath = "m/352'/coin_type'/account'/0'/0";
 	*/
 
+	if account >= hdkeychain.HardenedKeyStart {
+		err = ErrInvalidAccount
+		return
+	}
+
 	// m/352'
 	purpose, err := master.Derive(352 + hdkeychain.HardenedKeyStart)
 	if err != nil {
 		return
 	}
 
-	var coinType *hdkeychain.ExtendedKey
-
-	if mainnet {
-		// m/352'/0'
-		coinType, err = purpose.Derive(0 + hdkeychain.HardenedKeyStart)
-		if err != nil {
-			return
-		}
-	} else {
-		// m/352'/1'
-		coinType, err = purpose.Derive(1 + hdkeychain.HardenedKeyStart)
-		if err != nil {
-			return
-		}
+	// m/352'/0' for mainnet, m/352'/1' for all test networks
+	coinType, err := purpose.Derive(network.CoinType() + hdkeychain.HardenedKeyStart)
+	if err != nil {
+		return
 	}
 
-	// m/352'/0'/0'
-	acct0, err := coinType.Derive(0 + hdkeychain.HardenedKeyStart)
+	// m/352'/0'/account'
+	acct, err := coinType.Derive(account + hdkeychain.HardenedKeyStart)
 	if err != nil {
 		return
 	}
 
-	// m/352'/0'/0'/1'
-	scanExternal, err := acct0.Derive(1 + hdkeychain.HardenedKeyStart)
+	// m/352'/0'/account'/1'
+	scanExternal, err := acct.Derive(1 + hdkeychain.HardenedKeyStart)
 	if err != nil {
 		return
 	}
 
-	// m/352'/0'/0'/0'
-	spendExternal, err := acct0.Derive(0 + hdkeychain.HardenedKeyStart)
+	// m/352'/0'/account'/0'
+	spendExternal, err := acct.Derive(0 + hdkeychain.HardenedKeyStart)
 	if err != nil {
 		return
 	}
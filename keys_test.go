@@ -24,7 +24,7 @@ func TestDeriveKeysFromMaster(t *testing.T) {
 		return
 	}
 
-	scanSecret, spendSecret, err := DeriveKeysFromMaster(master, true)
+	scanSecret, spendSecret, err := DeriveKeysFromMaster(master, Mainnet)
 	if err != nil {
 		t.Errorf("error deriving keys: %v", err)
 		return
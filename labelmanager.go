@@ -0,0 +1,149 @@
+package bip352
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// LabelManager allocates labels with sequential m for a receiver and keeps them ready for scanning.
+// The change label m=0 is always created, BIP352 recommends to always scan for it.
+// A LabelManager is not safe for concurrent use.
+type LabelManager struct {
+	scanSecKey  [32]byte
+	scanPubKey  [33]byte
+	spendPubKey [33]byte
+	network     Network
+	labels      []*Label // indexed by m
+	labelSet    *LabelSet
+}
+
+type labelManagerJSON struct {
+	Labels []*Label `json:"labels"`
+}
+
+// NewLabelManager creates a LabelManager holding the change label m=0.
+// Previously allocated labels can be restored with json.Unmarshal.
+func NewLabelManager(scanSecKey [32]byte, spendPubKey [33]byte, network Network) (*LabelManager, error) {
+	if err := validatePublicKey(spendPubKey); err != nil {
+		return nil, err
+	}
+
+	manager := &LabelManager{
+		scanSecKey:  scanSecKey,
+		scanPubKey:  *PubKeyFromSecKey(&scanSecKey),
+		spendPubKey: spendPubKey,
+		network:     network,
+		labelSet:    NewLabelSet(),
+	}
+
+	if _, err := manager.NewLabel(); err != nil {
+		return nil, err
+	}
+
+	return manager, nil
+}
+
+// NewLabel allocates the label with the next m and fills its address
+func (lm *LabelManager) NewLabel() (*Label, error) {
+	m := uint32(len(lm.labels))
+
+	label, err := lm.createLabel(m)
+	if err != nil {
+		return nil, err
+	}
+
+	lm.labels = append(lm.labels, label)
+	lm.labelSet.Add(label)
+	return label, nil
+}
+
+// Label returns the label for m if it was allocated
+func (lm *LabelManager) Label(m uint32) (*Label, bool) {
+	if int(m) >= len(lm.labels) {
+		return nil, false
+	}
+	return lm.labels[m], true
+}
+
+// ChangeLabel returns the label m=0 which is reserved for change.
+// Returns ErrLabelMissing for a LabelManager that was not created with NewLabelManager.
+func (lm *LabelManager) ChangeLabel() (*Label, error) {
+	if len(lm.labels) == 0 {
+		return nil, ErrLabelMissing
+	}
+	return lm.labels[0], nil
+}
+
+// Labels returns all allocated labels ordered by m
+func (lm *LabelManager) Labels() []*Label {
+	labels := make([]*Label, len(lm.labels))
+	copy(labels, lm.labels)
+	return labels
+}
+
+// LabelSet returns the labels for scanning, see ReceiverScanTransactionWithLabelSet.
+// The set is updated when new labels are allocated and must not be modified.
+func (lm *LabelManager) LabelSet() *LabelSet {
+	return lm.labelSet
+}
+
+func (lm *LabelManager) MarshalJSON() ([]byte, error) {
+	return json.Marshal(labelManagerJSON{Labels: lm.labels})
+}
+
+// UnmarshalJSON restores the labels of a LabelManager created with NewLabelManager.
+// The labels are recomputed from the keys, returns ErrLabelMismatch if they were created with other keys.
+// The LabelSet returned by LabelSet is updated in place.
+func (lm *LabelManager) UnmarshalJSON(data []byte) error {
+	var alias labelManagerJSON
+	err := json.Unmarshal(data, &alias)
+	if err != nil {
+		return err
+	}
+	if len(alias.Labels) == 0 {
+		return fmt.Errorf("%w: change label missing", ErrLabelMismatch)
+	}
+
+	labels := make([]*Label, len(alias.Labels))
+	for i, stored := range alias.Labels {
+		if stored.M != uint32(i) {
+			return fmt.Errorf("%w: expected m=%d got m=%d", ErrLabelMismatch, i, stored.M)
+		}
+
+		label, err := lm.createLabel(stored.M)
+		if err != nil {
+			return err
+		}
+		if *label != *stored {
+			return fmt.Errorf("%w: m=%d", ErrLabelMismatch, stored.M)
+		}
+		labels[i] = label
+	}
+
+	if lm.labelSet == nil {
+		lm.labelSet = NewLabelSet()
+	}
+	for _, label := range lm.labels {
+		lm.labelSet.Remove(label.PubKey)
+	}
+	for _, label := range labels {
+		lm.labelSet.Add(label)
+	}
+
+	lm.labels = labels
+	return nil
+}
+
+func (lm *LabelManager) createLabel(m uint32) (*Label, error) {
+	label, err := CreateLabel(&lm.scanSecKey, m)
+	if err != nil {
+		return nil, err
+	}
+
+	label.Address, err = CreateLabeledAddress(&lm.scanPubKey, &lm.spendPubKey, lm.network, 0, &lm.scanSecKey, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &label, nil
+}
@@ -0,0 +1,106 @@
+package bip352
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelManager(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	// case with labels
+	testCase := caseData[12].Receiving[0]
+	require.NotEmpty(t, testCase.Given.Labels)
+
+	scanSecKey, spendSecKey := testVectorKeys(t, testCase.Given.KeyMaterial.ScanPrivKey, testCase.Given.KeyMaterial.SpendPrivKey)
+	spendPubKey := PubKeyFromSecKey(&spendSecKey)
+
+	manager, err := NewLabelManager(scanSecKey, *spendPubKey, Mainnet)
+	require.NoError(t, err)
+	require.Len(t, manager.Labels(), 1)
+	changeLabel, err := manager.ChangeLabel()
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), changeLabel.M)
+
+	_, err = new(LabelManager).ChangeLabel()
+	require.ErrorIs(t, err, ErrLabelMissing)
+
+	// the vectors use m=1001337 as well, only allocate the small labels
+	var maxM uint32
+	var labels []uint32
+	for _, m := range testCase.Given.Labels {
+		if m > 100 {
+			continue
+		}
+		maxM = max(maxM, m)
+		labels = append(labels, m)
+	}
+	require.NotEmpty(t, labels)
+	for m := uint32(1); m <= maxM; m++ {
+		label, err := manager.NewLabel()
+		require.NoError(t, err)
+		require.Equal(t, m, label.M)
+	}
+
+	for _, m := range labels {
+		label, ok := manager.Label(m)
+		require.True(t, ok)
+
+		expected, err := CreateLabel(&scanSecKey, m)
+		require.NoError(t, err)
+		require.Equal(t, expected.PubKey, label.PubKey)
+		require.Contains(t, testCase.Expected.Addresses, label.Address)
+	}
+	_, ok := manager.Label(maxM + 1)
+	require.False(t, ok)
+	require.Equal(t, int(maxM)+1, manager.LabelSet().Len())
+
+	// restore the labels
+	data, err := json.Marshal(manager)
+	require.NoError(t, err)
+
+	restored, err := NewLabelManager(scanSecKey, *spendPubKey, Mainnet)
+	require.NoError(t, err)
+	labelSet := restored.LabelSet()
+	require.NoError(t, json.Unmarshal(data, restored))
+	require.Equal(t, manager.Labels(), restored.Labels())
+	require.Equal(t, manager.LabelSet().Len(), restored.LabelSet().Len())
+
+	// a set handed out before restoring sees the restored labels
+	require.Same(t, labelSet, restored.LabelSet())
+	for _, m := range labels {
+		label, _ := restored.Label(m)
+		found, ok := labelSet.Get(xOnlyKey(label.PubKey))
+		require.True(t, ok)
+		require.Same(t, label, found)
+	}
+
+	label, err := restored.NewLabel()
+	require.NoError(t, err)
+	require.Equal(t, maxM+1, label.M)
+
+	// labels of other keys are rejected
+	otherScanSecKey, _ := testKeys()
+	other, err := NewLabelManager(otherScanSecKey, *spendPubKey, Mainnet)
+	require.NoError(t, err)
+	require.ErrorIs(t, json.Unmarshal(data, other), ErrLabelMismatch)
+}
+
+func TestLabelJSON(t *testing.T) {
+	scanSecKey, _ := testKeys()
+	label, err := CreateLabel(&scanSecKey, 7)
+	require.NoError(t, err)
+	label.Address = testAddress
+
+	data, err := json.Marshal(&label)
+	require.NoError(t, err)
+
+	var decoded Label
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, label, decoded)
+
+	require.Error(t, json.Unmarshal([]byte(`{"pub_key":"02","tweak":"","m":1}`), &decoded))
+}
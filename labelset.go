@@ -0,0 +1,43 @@
+package bip352
+
+// LabelSet indexes labels by the x-only key of their public key.
+// Matching an output against a LabelSet takes constant time regardless of the number of labels.
+// A LabelSet is not safe for concurrent modification.
+type LabelSet struct {
+	labels map[[32]byte]*Label
+}
+
+// NewLabelSet creates a LabelSet containing labels
+func NewLabelSet(labels ...*Label) *LabelSet {
+	s := &LabelSet{labels: make(map[[32]byte]*Label, len(labels))}
+	for _, label := range labels {
+		s.Add(label)
+	}
+	return s
+}
+
+// Add inserts a label, an existing label with the same public key is replaced
+func (s *LabelSet) Add(label *Label) {
+	s.labels[xOnlyKey(label.PubKey)] = label
+}
+
+// Remove deletes the label with the given public key
+func (s *LabelSet) Remove(labelPubKey [33]byte) {
+	delete(s.labels, xOnlyKey(labelPubKey))
+}
+
+// Get returns the label with the given x-only public key
+func (s *LabelSet) Get(xOnlyPubKey [32]byte) (*Label, bool) {
+	label, ok := s.labels[xOnlyPubKey]
+	return label, ok
+}
+
+func (s *LabelSet) Len() int {
+	return len(s.labels)
+}
+
+func xOnlyKey(pubKey [33]byte) [32]byte {
+	var xOnly [32]byte
+	copy(xOnly[:], pubKey[1:])
+	return xOnly
+}
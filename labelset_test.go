@@ -0,0 +1,114 @@
+package bip352
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiverScanTransactionWithLabelSet(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	for _, cases := range caseData {
+		for _, testCase := range cases.Receiving {
+			secKeyScan, secKeySpend := testVectorKeys(t, testCase.Given.KeyMaterial.ScanPrivKey, testCase.Given.KeyMaterial.SpendPrivKey)
+			spendPubKey := PubKeyFromSecKey(&secKeySpend)
+
+			labelSet := NewLabelSet()
+			for _, m := range testCase.Given.Labels {
+				label, err := CreateLabel(&secKeyScan, m)
+				require.NoError(t, err)
+				labelSet.Add(&label)
+			}
+
+			tx, fetcher := txFromTestVins(t, testCase.Given.Vin, testCase.Given.Outputs)
+			txData, err := ExtractTxData(tx, fetcher)
+			if err != nil {
+				require.ErrorIs(t, err, ErrNoEligibleVins)
+				continue
+			}
+
+			sharedSecret, err := CreateSharedSecret(txData.PublicKeySum, &secKeyScan, txData.InputHash)
+			require.NoError(t, err)
+
+			foundOutputs, err := ReceiverScanTransactionWithLabelSet(spendPubKey, labelSet, txData.Outputs, sharedSecret)
+			require.NoError(t, err, cases.Comment)
+			require.Len(t, foundOutputs, len(testCase.Expected.Outputs), cases.Comment)
+
+			for i, foundOutput := range foundOutputs {
+				require.Equal(t, testCase.Expected.Outputs[i].PubKey, hex.EncodeToString(foundOutput.Output[:]), cases.Comment)
+				require.Equal(t, testCase.Expected.Outputs[i].PrivKeyTweak, hex.EncodeToString(foundOutput.SecKeyTweak[:]), cases.Comment)
+			}
+		}
+	}
+}
+
+func TestLabelSet(t *testing.T) {
+	scanSecKey, _ := testKeys()
+
+	label0, err := CreateLabel(&scanSecKey, 0)
+	require.NoError(t, err)
+	label1, err := CreateLabel(&scanSecKey, 1)
+	require.NoError(t, err)
+
+	labelSet := NewLabelSet(&label0, &label1)
+	require.Equal(t, 2, labelSet.Len())
+
+	// the parity of the key is ignored
+	label, ok := labelSet.Get(xOnlyKey(label1.PubKey))
+	require.True(t, ok)
+	require.Equal(t, uint32(1), label.M)
+
+	labelSet.Remove(label1.PubKey)
+	require.Equal(t, 1, labelSet.Len())
+	_, ok = labelSet.Get(xOnlyKey(label1.PubKey))
+	require.False(t, ok)
+}
+
+// benchLabels caches the labels for the benchmarks, creating 100k labels takes a while
+var benchLabels []*Label
+
+func BenchmarkMatchLabels(b *testing.B) {
+	scanSecKey, spendSecKey := testKeys()
+	spendPubKey := PubKeyFromSecKey(&spendSecKey)
+	sharedSecret := *PubKeyFromSecKey(&scanSecKey)
+
+	for _, n := range []int{1, 100, 100_000} {
+		for len(benchLabels) < n {
+			label, err := CreateLabel(&scanSecKey, uint32(len(benchLabels)))
+			require.NoError(b, err)
+			benchLabels = append(benchLabels, &label)
+		}
+		labels := benchLabels[:n]
+		labelSet := NewLabelSet(labels...)
+
+		// the output pays to the last label, the worst case for the slice
+		labeledSpendPubKey, err := CreateLabelledSpendPubKey(spendPubKey, &labels[n-1].PubKey)
+		require.NoError(b, err)
+		output, err := CreateOutputPubKey(sharedSecret, labeledSpendPubKey, 0)
+		require.NoError(b, err)
+
+		b.Run(fmt.Sprintf("slice-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				foundOutputs, err := ReceiverScanTransactionWithSharedSecret(
+					[32]byte{}, spendPubKey, labels, [][32]byte{output}, &sharedSecret,
+				)
+				require.NoError(b, err)
+				require.Len(b, foundOutputs, 1)
+			}
+		})
+
+		b.Run(fmt.Sprintf("map-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				foundOutputs, err := ReceiverScanTransactionWithLabelSet(
+					spendPubKey, labelSet, [][32]byte{output}, &sharedSecret,
+				)
+				require.NoError(b, err)
+				require.Len(b, foundOutputs, 1)
+			}
+		})
+	}
+}
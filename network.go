@@ -0,0 +1,128 @@
+package bip352
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Network identifies the bitcoin network keys and addresses are meant for
+type Network uint8
+
+const (
+	Mainnet Network = iota
+	Testnet         // testnet3
+	Testnet4
+	Signet
+	Regtest
+)
+
+// testNet4Params the btcd version in use does not ship testnet4 parameters.
+// Key and address encodings are the same as for testnet3, only the network identifiers differ.
+var testNet4Params = func() chaincfg.Params {
+	params := chaincfg.TestNet3Params
+	params.Name = "testnet4"
+	params.Net = wire.BitcoinNet(0x283f161c)
+	params.DefaultPort = "48333"
+	params.DNSSeeds = nil
+	params.Checkpoints = nil
+	return params
+}()
+
+// HRP returns the human-readable part used for silent payment addresses on the network
+func (n Network) HRP() string {
+	switch n {
+	case Mainnet:
+		return "sp"
+	case Regtest:
+		return "sprt"
+	default:
+		return "tsp"
+	}
+}
+
+// Params returns the chain parameters of the network
+func (n Network) Params() *chaincfg.Params {
+	switch n {
+	case Mainnet:
+		return &chaincfg.MainNetParams
+	case Testnet4:
+		return &testNet4Params
+	case Signet:
+		return &chaincfg.SigNetParams
+	case Regtest:
+		return &chaincfg.RegressionNetParams
+	default:
+		return &chaincfg.TestNet3Params
+	}
+}
+
+// CoinType returns the BIP44 coin type used in the derivation path of the keys
+func (n Network) CoinType() uint32 {
+	if n == Mainnet {
+		return 0
+	}
+	return 1
+}
+
+func (n Network) String() string {
+	switch n {
+	case Mainnet:
+		return "mainnet"
+	case Testnet:
+		return "testnet"
+	case Testnet4:
+		return "testnet4"
+	case Signet:
+		return "signet"
+	case Regtest:
+		return "regtest"
+	default:
+		return "unknown"
+	}
+}
+
+// NetworkFromHRP returns the network for a silent payment address hrp.
+// Testnet, testnet4 and signet share the "tsp" hrp, ErrNetworkAmbiguous is returned for it.
+// Compare the hrp with Network.HRP if the network is known.
+func NetworkFromHRP(hrp string) (Network, error) {
+	switch hrp {
+	case "sp":
+		return Mainnet, nil
+	case "tsp":
+		return 0, ErrNetworkAmbiguous
+	case "sprt":
+		return Regtest, nil
+	default:
+		return 0, AddressHRPError
+	}
+}
+
+// ParseNetwork returns the network for the name returned by Network.String
+func ParseNetwork(name string) (Network, error) {
+	for _, network := range []Network{Mainnet, Testnet, Testnet4, Signet, Regtest} {
+		if network.String() == name {
+			return network, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnknownNetwork, name)
+}
+
+// MarshalText implements encoding.TextMarshaler, networks are encoded by name
+func (n Network) MarshalText() ([]byte, error) {
+	if _, err := ParseNetwork(n.String()); err != nil {
+		return nil, err
+	}
+	return []byte(n.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (n *Network) UnmarshalText(text []byte) error {
+	network, err := ParseNetwork(string(text))
+	if err != nil {
+		return err
+	}
+	*n = network
+	return nil
+}
@@ -0,0 +1,102 @@
+package bip352
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkParams(t *testing.T) {
+	testCases := []struct {
+		network Network
+		hrp     string
+		params  string
+	}{
+		{network: Mainnet, hrp: "sp", params: chaincfg.MainNetParams.Name},
+		{network: Testnet, hrp: "tsp", params: chaincfg.TestNet3Params.Name},
+		{network: Testnet4, hrp: "tsp", params: "testnet4"},
+		{network: Signet, hrp: "tsp", params: chaincfg.SigNetParams.Name},
+		{network: Regtest, hrp: "sprt", params: chaincfg.RegressionNetParams.Name},
+	}
+
+	for _, testCase := range testCases {
+		require.Equal(t, testCase.hrp, testCase.network.HRP(), testCase.network.String())
+		require.Equal(t, testCase.params, testCase.network.Params().Name, testCase.network.String())
+	}
+
+	// testnet4 must not alter the shared testnet3 params
+	require.Equal(t, "testnet3", chaincfg.TestNet3Params.Name)
+
+	for _, testCase := range testCases {
+		network, err := ParseNetwork(testCase.network.String())
+		require.NoError(t, err)
+		require.Equal(t, testCase.network, network)
+	}
+	_, err := ParseNetwork("testnet3")
+	require.ErrorIs(t, err, ErrUnknownNetwork)
+	_, err = Network(42).MarshalText()
+	require.ErrorIs(t, err, ErrUnknownNetwork)
+}
+
+func TestRegtestAddress(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	scanSecret, spendSecret, err := KeysFromMnemonic(mnemonic, "", Regtest)
+	require.NoError(t, err)
+
+	// all test networks use the same coin type
+	scanSecretSignet, spendSecretSignet, err := KeysFromMnemonic(mnemonic, "", Signet)
+	require.NoError(t, err)
+	require.Equal(t, scanSecretSignet, scanSecret)
+	require.Equal(t, spendSecretSignet, spendSecret)
+
+	scanSecretMainnet, _, err := KeysFromMnemonic(mnemonic, "", Mainnet)
+	require.NoError(t, err)
+	require.NotEqual(t, scanSecretMainnet, scanSecret)
+
+	address, err := CreateAddress(PubKeyFromSecKey(&scanSecret), PubKeyFromSecKey(&spendSecret), Regtest, 0)
+	require.NoError(t, err)
+	require.Equal(t, "sprt1q", address[:6])
+
+	scanPubKey, spendPubKey, err := DecodeSilentPaymentAddressToKeys(address, Regtest)
+	require.NoError(t, err)
+	require.Equal(t, *PubKeyFromSecKey(&scanSecret), scanPubKey)
+	require.Equal(t, *PubKeyFromSecKey(&spendSecret), spendPubKey)
+
+	_, _, err = DecodeSilentPaymentAddressToKeys(address, Testnet)
+	require.ErrorIs(t, err, AddressHRPError)
+
+	parsed, err := ParseAddress(address)
+	require.NoError(t, err)
+	require.Equal(t, Regtest, parsed.Network())
+}
+
+func TestTestNetworkAddress(t *testing.T) {
+	address, err := ParseAddress(testAddress)
+	require.NoError(t, err)
+
+	for _, network := range []Network{Testnet, Testnet4, Signet} {
+		testnetAddress, err := NewSilentPaymentAddress(address.ScanKey(), address.SpendKey(), network, 0)
+		require.NoError(t, err)
+		encoded := testnetAddress.String()
+		require.Equal(t, "tsp1q", encoded[:5])
+		require.True(t, IsSilentPaymentAddress(encoded))
+
+		// the network can not be derived from the shared hrp
+		_, err = ParseAddress(encoded)
+		require.ErrorIs(t, err, ErrNetworkAmbiguous)
+
+		parsed, err := ParseAddressForNetwork(encoded, network)
+		require.NoError(t, err)
+		require.Equal(t, testnetAddress, parsed)
+
+		_, err = ParseAddressForNetwork(encoded, Mainnet)
+		require.ErrorIs(t, err, AddressHRPError)
+		_, err = ParseAddressForNetwork(encoded, Regtest)
+		require.ErrorIs(t, err, AddressHRPError)
+	}
+
+	_, err = NetworkFromHRP("tsp")
+	require.ErrorIs(t, err, ErrNetworkAmbiguous)
+}
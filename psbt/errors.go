@@ -0,0 +1,21 @@
+package psbt
+
+import "errors"
+
+var (
+	ErrInvalidInputIndex = errors.New("input index out of range")
+
+	ErrInvalidOutputIndex = errors.New("output index out of range")
+
+	ErrInvalidFieldLength = errors.New("invalid length for silent payment psbt field")
+
+	ErrMissingPrevOut = errors.New("input is missing the utxo it spends")
+
+	ErrMissingInputPublicKey = errors.New("public key of eligible input is unknown")
+
+	ErrMissingECDHShare = errors.New("ecdh share missing for scan key")
+
+	ErrMissingDLEQProof = errors.New("dleq proof missing for ecdh share")
+
+	ErrInvalidDLEQProof = errors.New("dleq proof of ecdh share is invalid")
+)
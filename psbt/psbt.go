@@ -0,0 +1,455 @@
+// Package psbt implements the BIP375 fields for sending to silent payment addresses with PSBTs.
+//
+// BIP375 is specified for PSBTv2. btcutil/psbt only supports v0 packets,
+// the fields are stored in the unknowns of the respective maps and can be used the same way.
+package psbt
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	btcpsbt "github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/setavenger/blindbit-lib/utils"
+	bip352 "github.com/setavenger/go-bip352"
+)
+
+// BIP375 key types
+const (
+	GlobalSPECDHShareType byte = 0x07
+	GlobalSPDLEQType      byte = 0x08
+	InputSPECDHShareType  byte = 0x1d
+	InputSPDLEQType       byte = 0x1e
+	OutputSPV0InfoType    byte = 0x09
+	OutputSPV0LabelType   byte = 0x0a
+)
+
+// ECDHShare is the share a·B_scan for one scan key.
+// a is either the secret key of a single input or the sum of the secret keys of all eligible inputs.
+type ECDHShare struct {
+	ScanKey [33]byte
+	Share   [33]byte
+	Proof   *[64]byte // DLEQ proof for the share, the field is optional in a psbt but ComputeOutputs requires it
+}
+
+// OutputInfo is the silent payment address an output pays to
+type OutputInfo struct {
+	ScanKey  [33]byte
+	SpendKey [33]byte
+	Label    *uint32 // optional label, only set for the sender's own change
+}
+
+// NewECDHShare computes the share a·B_scan together with a DLEQ proof.
+// For taproot inputs with an odd y-coordinate the negated secret key has to be passed.
+// auxRand should be fresh randomness.
+func NewECDHShare(secretKey [32]byte, scanKey [33]byte, auxRand *[32]byte) (ECDHShare, error) {
+	proof, err := bip352.GenerateDLEQProof(&secretKey, &scanKey, auxRand, nil, nil)
+	if err != nil {
+		return ECDHShare{}, err
+	}
+
+	share, err := bip352.CreateSharedSecret(&scanKey, &secretKey, nil)
+	if err != nil {
+		return ECDHShare{}, err
+	}
+
+	return ECDHShare{ScanKey: scanKey, Share: *share, Proof: &proof}, nil
+}
+
+// Verify checks the DLEQ proof of the share against the public key the share was computed with.
+// Shares without a proof are not valid.
+func (s *ECDHShare) Verify(publicKey *[33]byte) bool {
+	if s.Proof == nil {
+		return false
+	}
+	return bip352.VerifyDLEQProof(publicKey, &s.ScanKey, &s.Share, s.Proof, nil, nil)
+}
+
+// GlobalECDHShares returns the ECDH shares that cover all inputs of the packet
+func GlobalECDHShares(p *btcpsbt.Packet) ([]ECDHShare, error) {
+	return readECDHShares(p.Unknowns, GlobalSPECDHShareType, GlobalSPDLEQType)
+}
+
+// AddGlobalECDHShare adds an ECDH share that covers all inputs of the packet
+func AddGlobalECDHShare(p *btcpsbt.Packet, share ECDHShare) {
+	p.Unknowns = writeECDHShare(p.Unknowns, share, GlobalSPECDHShareType, GlobalSPDLEQType)
+}
+
+// InputECDHShares returns the ECDH shares of a single input
+func InputECDHShares(p *btcpsbt.Packet, index int) ([]ECDHShare, error) {
+	if index < 0 || index >= len(p.Inputs) {
+		return nil, ErrInvalidInputIndex
+	}
+	return readECDHShares(p.Inputs[index].Unknowns, InputSPECDHShareType, InputSPDLEQType)
+}
+
+// AddInputECDHShare adds an ECDH share for a single input
+func AddInputECDHShare(p *btcpsbt.Packet, index int, share ECDHShare) error {
+	if index < 0 || index >= len(p.Inputs) {
+		return ErrInvalidInputIndex
+	}
+	p.Inputs[index].Unknowns = writeECDHShare(
+		p.Inputs[index].Unknowns, share, InputSPECDHShareType, InputSPDLEQType,
+	)
+	return nil
+}
+
+// GetOutputInfo returns the silent payment info of an output, nil if the output does not pay to a silent payment address
+func GetOutputInfo(p *btcpsbt.Packet, index int) (*OutputInfo, error) {
+	if index < 0 || index >= len(p.Outputs) {
+		return nil, ErrInvalidOutputIndex
+	}
+
+	var info *OutputInfo
+	var label *uint32
+	for _, unknown := range p.Outputs[index].Unknowns {
+		switch {
+		case bytes.Equal(unknown.Key, []byte{OutputSPV0InfoType}):
+			if len(unknown.Value) != 66 {
+				return nil, ErrInvalidFieldLength
+			}
+			info = &OutputInfo{
+				ScanKey:  utils.ConvertToFixedLength33(unknown.Value[:33]),
+				SpendKey: utils.ConvertToFixedLength33(unknown.Value[33:]),
+			}
+		case bytes.Equal(unknown.Key, []byte{OutputSPV0LabelType}):
+			if len(unknown.Value) != 4 {
+				return nil, ErrInvalidFieldLength
+			}
+			m := binary.LittleEndian.Uint32(unknown.Value)
+			label = &m
+		}
+	}
+
+	if info != nil {
+		info.Label = label
+	}
+
+	return info, nil
+}
+
+// SetOutputInfo marks an output as paying to a silent payment address.
+// The script of the output is computed by ComputeOutputs.
+func SetOutputInfo(p *btcpsbt.Packet, index int, info OutputInfo) error {
+	if index < 0 || index >= len(p.Outputs) {
+		return ErrInvalidOutputIndex
+	}
+
+	unknowns := removeUnknowns(p.Outputs[index].Unknowns, OutputSPV0InfoType)
+	unknowns = removeUnknowns(unknowns, OutputSPV0LabelType)
+
+	unknowns = append(unknowns, &btcpsbt.Unknown{
+		Key:   []byte{OutputSPV0InfoType},
+		Value: append(info.ScanKey[:], info.SpendKey[:]...),
+	})
+	if info.Label != nil {
+		value := make([]byte, 4)
+		binary.LittleEndian.PutUint32(value, *info.Label)
+		unknowns = append(unknowns, &btcpsbt.Unknown{
+			Key:   []byte{OutputSPV0LabelType},
+			Value: value,
+		})
+	}
+
+	p.Outputs[index].Unknowns = unknowns
+	return nil
+}
+
+// ComputeOutputs computes the scripts of all silent payment outputs of the packet.
+// For every scan key either a global share or a share for every eligible input has to be present.
+// Every share needs a valid DLEQ proof, otherwise a single party could make the outputs unspendable.
+// ErrMissingDLEQProof is returned for shares without a proof and ErrInvalidDLEQProof for shares with an invalid proof.
+// The computed P2TR scripts are written to the unsigned transaction.
+func ComputeOutputs(p *btcpsbt.Packet) error {
+	vins, err := packetVins(p)
+	if err != nil {
+		return err
+	}
+
+	var pubKeys [][33]byte
+	var eligibleIndices []int
+	for i, vin := range vins {
+		if vin.PublicKey == nil {
+			continue
+		}
+		pubKeys = append(pubKeys, *vin.PublicKey)
+		eligibleIndices = append(eligibleIndices, i)
+	}
+
+	if len(pubKeys) == 0 {
+		return bip352.ErrNoEligibleVins
+	}
+
+	publicKeySum, err := bip352.SumPublicKeys(pubKeys)
+	if err != nil {
+		return err
+	}
+
+	var recipients []*bip352.Recipient
+	var outputIndices []int
+	shares := make(map[[33]byte][][33]byte)
+
+	for i := range p.Outputs {
+		info, err := GetOutputInfo(p, i)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			continue
+		}
+
+		scanPubKey, err := btcec.ParsePubKey(info.ScanKey[:])
+		if err != nil {
+			return err
+		}
+		spendPubKey, err := btcec.ParsePubKey(info.SpendKey[:])
+		if err != nil {
+			return err
+		}
+
+		recipients = append(recipients, &bip352.Recipient{
+			ScanPubKey:  scanPubKey,
+			SpendPubKey: spendPubKey,
+			Amount:      uint64(p.UnsignedTx.TxOut[i].Value),
+		})
+		outputIndices = append(outputIndices, i)
+
+		if _, ok := shares[info.ScanKey]; ok {
+			continue
+		}
+
+		shares[info.ScanKey], err = collectECDHShares(p, info.ScanKey, vins, eligibleIndices, publicKeySum)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	// the network is only needed to decode addresses, the recipients are given as keys
+	err = bip352.SenderCreateOutputsFromShares(recipients, vins, shares, bip352.Mainnet)
+	if err != nil {
+		return err
+	}
+
+	for i, recipient := range recipients {
+		p.UnsignedTx.TxOut[outputIndices[i]].PkScript = bip352.P2TRScript(recipient.Output)
+	}
+
+	return nil
+}
+
+// collectECDHShares returns the global share for the scan key or the shares of all eligible inputs
+func collectECDHShares(
+	p *btcpsbt.Packet,
+	scanKey [33]byte,
+	vins []*bip352.Vin,
+	eligibleIndices []int,
+	publicKeySum *[33]byte,
+) ([][33]byte, error) {
+	globalShares, err := GlobalECDHShares(p)
+	if err != nil {
+		return nil, err
+	}
+	for _, share := range globalShares {
+		if share.ScanKey != scanKey {
+			continue
+		}
+		if err := verifyECDHShare(&share, publicKeySum); err != nil {
+			return nil, err
+		}
+		return [][33]byte{share.Share}, nil
+	}
+
+	var shares [][33]byte
+	for _, index := range eligibleIndices {
+		inputShares, err := InputECDHShares(p, index)
+		if err != nil {
+			return nil, err
+		}
+
+		var found bool
+		for _, share := range inputShares {
+			if share.ScanKey != scanKey {
+				continue
+			}
+			if err := verifyECDHShare(&share, vins[index].PublicKey); err != nil {
+				return nil, err
+			}
+			shares = append(shares, share.Share)
+			found = true
+			break
+		}
+		if !found {
+			return nil, ErrMissingECDHShare
+		}
+	}
+
+	return shares, nil
+}
+
+// verifyECDHShare checks that the share has a valid DLEQ proof for publicKey
+func verifyECDHShare(share *ECDHShare, publicKey *[33]byte) error {
+	if share.Proof == nil {
+		return ErrMissingDLEQProof
+	}
+	if !share.Verify(publicKey) {
+		return ErrInvalidDLEQProof
+	}
+	return nil
+}
+
+// packetVins converts the inputs of the packet into Vins.
+// The public key is only set for inputs which are eligible for the shared secret derivation.
+func packetVins(p *btcpsbt.Packet) ([]*bip352.Vin, error) {
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, txIn := range p.UnsignedTx.TxIn {
+		prevOut, err := inputPrevOut(p, i)
+		if err != nil {
+			return nil, err
+		}
+		fetcher.AddPrevOut(txIn.PreviousOutPoint, prevOut)
+	}
+
+	vins, err := bip352.VinsFromTx(p.UnsignedTx, fetcher)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, vin := range vins {
+		vin.PublicKey, err = inputPublicKey(&p.Inputs[i], vin.ScriptPubKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return vins, nil
+}
+
+func inputPrevOut(p *btcpsbt.Packet, index int) (*wire.TxOut, error) {
+	pIn := p.Inputs[index]
+	if pIn.WitnessUtxo != nil {
+		return pIn.WitnessUtxo, nil
+	}
+
+	if pIn.NonWitnessUtxo != nil {
+		vout := p.UnsignedTx.TxIn[index].PreviousOutPoint.Index
+		if int(vout) < len(pIn.NonWitnessUtxo.TxOut) {
+			return pIn.NonWitnessUtxo.TxOut[vout], nil
+		}
+	}
+
+	return nil, ErrMissingPrevOut
+}
+
+// inputPublicKey returns the public key of an eligible input, nil if the input is not eligible.
+// Taproot keys are returned with an even y-coordinate.
+func inputPublicKey(pIn *btcpsbt.PInput, scriptPubKey []byte) (*[33]byte, error) {
+	switch {
+	case bip352.IsP2TR(scriptPubKey):
+		if bytes.Equal(pIn.TaprootInternalKey, bip352.NumsH) {
+			return nil, nil
+		}
+		pubKey := utils.ConvertToFixedLength33(append([]byte{0x02}, scriptPubKey[2:]...))
+		return &pubKey, nil
+
+	case bip352.IsP2WPKH(scriptPubKey), bip352.IsP2PKH(scriptPubKey):
+		return signingPublicKey(pIn)
+
+	case bip352.IsP2SH(scriptPubKey):
+		// only P2SH-P2WPKH is eligible
+		if !bip352.IsP2WPKH(pIn.RedeemScript) {
+			return nil, nil
+		}
+		return signingPublicKey(pIn)
+
+	default:
+		return nil, nil
+	}
+}
+
+// signingPublicKey returns the key a single key input is signed with.
+// Uncompressed keys are not eligible.
+func signingPublicKey(pIn *btcpsbt.PInput) (*[33]byte, error) {
+	var rawKey []byte
+	switch {
+	case len(pIn.Bip32Derivation) == 1:
+		rawKey = pIn.Bip32Derivation[0].PubKey
+	case len(pIn.PartialSigs) == 1:
+		rawKey = pIn.PartialSigs[0].PubKey
+	default:
+		return nil, ErrMissingInputPublicKey
+	}
+
+	if len(rawKey) != btcec.PubKeyBytesLenCompressed {
+		return nil, nil
+	}
+
+	pubKey := utils.ConvertToFixedLength33(rawKey)
+	return &pubKey, nil
+}
+
+func readECDHShares(unknowns []*btcpsbt.Unknown, shareType, proofType byte) ([]ECDHShare, error) {
+	var shares []ECDHShare
+	proofs := make(map[[33]byte]*[64]byte)
+
+	for _, unknown := range unknowns {
+		if len(unknown.Key) == 0 {
+			continue
+		}
+
+		switch unknown.Key[0] {
+		case shareType:
+			if len(unknown.Key) != 34 || len(unknown.Value) != 33 {
+				return nil, ErrInvalidFieldLength
+			}
+			shares = append(shares, ECDHShare{
+				ScanKey: utils.ConvertToFixedLength33(unknown.Key[1:]),
+				Share:   utils.ConvertToFixedLength33(unknown.Value),
+			})
+		case proofType:
+			if len(unknown.Key) != 34 || len(unknown.Value) != 64 {
+				return nil, ErrInvalidFieldLength
+			}
+			var proof [64]byte
+			copy(proof[:], unknown.Value)
+			proofs[utils.ConvertToFixedLength33(unknown.Key[1:])] = &proof
+		}
+	}
+
+	for i := range shares {
+		shares[i].Proof = proofs[shares[i].ScanKey]
+	}
+
+	return shares, nil
+}
+
+func writeECDHShare(unknowns []*btcpsbt.Unknown, share ECDHShare, shareType, proofType byte) []*btcpsbt.Unknown {
+	shareKey := append([]byte{shareType}, share.ScanKey[:]...)
+	proofKey := append([]byte{proofType}, share.ScanKey[:]...)
+
+	unknowns = removeUnknowns(unknowns, shareKey...)
+	unknowns = removeUnknowns(unknowns, proofKey...)
+
+	unknowns = append(unknowns, &btcpsbt.Unknown{Key: shareKey, Value: share.Share[:]})
+	if share.Proof != nil {
+		unknowns = append(unknowns, &btcpsbt.Unknown{Key: proofKey, Value: share.Proof[:]})
+	}
+
+	return unknowns
+}
+
+// removeUnknowns removes all entries with the exact key
+func removeUnknowns(unknowns []*btcpsbt.Unknown, key ...byte) []*btcpsbt.Unknown {
+	filtered := unknowns[:0]
+	for _, unknown := range unknowns {
+		if !bytes.Equal(unknown.Key, key) {
+			filtered = append(filtered, unknown)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,308 @@
+package psbt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	btcpsbt "github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/setavenger/blindbit-lib/utils"
+	bip352 "github.com/setavenger/go-bip352"
+	"github.com/stretchr/testify/require"
+)
+
+type sendingTestCase struct {
+	Comment string `json:"comment"`
+	Sending []struct {
+		Given struct {
+			Vin []struct {
+				Txid    string `json:"txid"`
+				Vout    uint32 `json:"vout"`
+				Prevout struct {
+					ScriptPubKey struct {
+						Hex string `json:"hex"`
+					} `json:"scriptPubKey"`
+				} `json:"prevout"`
+				PrivateKey string `json:"private_key"`
+			} `json:"vin"`
+			Recipients []string `json:"recipients"`
+		} `json:"given"`
+		Expected struct {
+			Outputs []string `json:"outputs"`
+		} `json:"expected"`
+	} `json:"sending"`
+}
+
+func loadSendingTestCases(t *testing.T) []sendingTestCase {
+	data, err := os.ReadFile("../test_data/send_and_receive_test_vectors_modified.json")
+	require.NoError(t, err)
+
+	var testCases []sendingTestCase
+	require.NoError(t, json.Unmarshal(data, &testCases))
+	return testCases
+}
+
+// ecdhShare computes a·B_scan, taproot keys are negated if they have an odd y-coordinate
+func ecdhShare(t *testing.T, secretKey [32]byte, scanKey [33]byte, taproot bool) [33]byte {
+	if taproot && pubKeyIsOdd(t, secretKey) {
+		secretKey = bip352.NegateSecretKey(secretKey)
+	}
+	sharedSecret, err := bip352.CreateSharedSecret(&scanKey, &secretKey, nil)
+	require.NoError(t, err)
+	return *sharedSecret
+}
+
+func pubKeyIsOdd(t *testing.T, secretKey [32]byte) bool {
+	return bip352.PubKeyFromSecKey(&secretKey)[0] == 0x03
+}
+
+func TestComputeOutputs(t *testing.T) {
+	// the cases with ineligible inputs are covered by the extraction tests
+	for i, testCase := range loadSendingTestCases(t)[:19] {
+		sending := testCase.Sending[0]
+
+		tx := wire.NewMsgTx(2)
+		var secretKeys [][32]byte
+		var scriptPubKeys [][]byte
+		for _, vin := range sending.Given.Vin {
+			hash, err := chainhash.NewHashFromStr(vin.Txid)
+			require.NoError(t, err)
+			tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, vin.Vout), nil, nil))
+
+			secretKey, err := hex.DecodeString(vin.PrivateKey)
+			require.NoError(t, err)
+			secretKeys = append(secretKeys, utils.ConvertToFixedLength32(secretKey))
+
+			scriptPubKey, err := hex.DecodeString(vin.Prevout.ScriptPubKey.Hex)
+			require.NoError(t, err)
+			scriptPubKeys = append(scriptPubKeys, scriptPubKey)
+		}
+
+		var addresses []bip352.SilentPaymentAddress
+		for range sending.Given.Recipients {
+			tx.AddTxOut(wire.NewTxOut(1000, nil))
+		}
+
+		packet, err := btcpsbt.NewFromUnsignedTx(tx)
+		require.NoError(t, err)
+
+		for j := range packet.Inputs {
+			packet.Inputs[j].WitnessUtxo = wire.NewTxOut(10_000, scriptPubKeys[j])
+			if !bip352.IsP2TR(scriptPubKeys[j]) {
+				_, pubKey := btcec.PrivKeyFromBytes(secretKeys[j][:])
+				packet.Inputs[j].Bip32Derivation = []*btcpsbt.Bip32Derivation{
+					{PubKey: pubKey.SerializeCompressed()},
+				}
+			}
+		}
+
+		for j, recipient := range sending.Given.Recipients {
+			address, err := bip352.ParseAddress(recipient)
+			require.NoError(t, err)
+			addresses = append(addresses, address)
+
+			require.NoError(t, SetOutputInfo(packet, j, OutputInfo{
+				ScanKey:  address.ScanKey(),
+				SpendKey: address.SpendKey(),
+			}))
+		}
+
+		// alternate between aggregated global shares and per input shares, both come with a proof
+		for _, address := range addresses {
+			if i%2 == 0 {
+				var aggregated [][33]byte
+				var evenSecretKeys [][32]byte
+				for j, secretKey := range secretKeys {
+					aggregated = append(aggregated, ecdhShare(t, secretKey, address.ScanKey(), bip352.IsP2TR(scriptPubKeys[j])))
+					if bip352.IsP2TR(scriptPubKeys[j]) && pubKeyIsOdd(t, secretKey) {
+						secretKey = bip352.NegateSecretKey(secretKey)
+					}
+					evenSecretKeys = append(evenSecretKeys, secretKey)
+				}
+				shareSum, err := bip352.SumPublicKeys(aggregated)
+				require.NoError(t, err)
+
+				share, err := NewECDHShare(bip352.RecursiveAddPrivateKeys(evenSecretKeys), address.ScanKey(), &[32]byte{})
+				require.NoError(t, err)
+				require.Equal(t, *shareSum, share.Share)
+				AddGlobalECDHShare(packet, share)
+				continue
+			}
+
+			for j, secretKey := range secretKeys {
+				if bip352.IsP2TR(scriptPubKeys[j]) && pubKeyIsOdd(t, secretKey) {
+					secretKey = bip352.NegateSecretKey(secretKey)
+				}
+				share, err := NewECDHShare(secretKey, address.ScanKey(), &[32]byte{})
+				require.NoError(t, err)
+				require.Equal(t, ecdhShare(t, secretKeys[j], address.ScanKey(), bip352.IsP2TR(scriptPubKeys[j])), share.Share)
+				require.NoError(t, AddInputECDHShare(packet, j, share))
+			}
+		}
+
+		// the fields have to survive a serialisation round trip
+		var buf bytes.Buffer
+		require.NoError(t, packet.Serialize(&buf))
+		packet, err = btcpsbt.NewFromRawBytes(&buf, false)
+		require.NoError(t, err)
+
+		require.NoError(t, ComputeOutputs(packet), testCase.Comment)
+
+		for _, txOut := range packet.UnsignedTx.TxOut {
+			require.True(t, bip352.IsP2TR(txOut.PkScript))
+			require.Contains(t, sending.Expected.Outputs, hex.EncodeToString(txOut.PkScript[2:]), testCase.Comment)
+		}
+	}
+}
+
+func TestComputeOutputsMissingShare(t *testing.T) {
+	testCase := loadSendingTestCases(t)[0].Sending[0]
+
+	tx := wire.NewMsgTx(2)
+	for _, vin := range testCase.Given.Vin {
+		hash, err := chainhash.NewHashFromStr(vin.Txid)
+		require.NoError(t, err)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, vin.Vout), nil, nil))
+	}
+	tx.AddTxOut(wire.NewTxOut(1000, nil))
+
+	packet, err := btcpsbt.NewFromUnsignedTx(tx)
+	require.NoError(t, err)
+
+	// prevouts are required
+	require.ErrorIs(t, ComputeOutputs(packet), ErrMissingPrevOut)
+
+	var shares []ECDHShare
+	address, err := bip352.ParseAddress(testCase.Given.Recipients[0])
+	require.NoError(t, err)
+	for j, vin := range testCase.Given.Vin {
+		scriptPubKey, _ := hex.DecodeString(vin.Prevout.ScriptPubKey.Hex)
+		secretKeyBytes, _ := hex.DecodeString(vin.PrivateKey)
+		secretKey := utils.ConvertToFixedLength32(secretKeyBytes)
+
+		packet.Inputs[j].WitnessUtxo = wire.NewTxOut(10_000, scriptPubKey)
+		share, err := NewECDHShare(secretKey, address.ScanKey(), &[32]byte{})
+		require.NoError(t, err)
+		shares = append(shares, share)
+	}
+
+	require.NoError(t, SetOutputInfo(packet, 0, OutputInfo{ScanKey: address.ScanKey(), SpendKey: address.SpendKey()}))
+
+	// public keys of eligible inputs are required
+	require.ErrorIs(t, ComputeOutputs(packet), ErrMissingInputPublicKey)
+
+	for j, vin := range testCase.Given.Vin {
+		secretKeyBytes, _ := hex.DecodeString(vin.PrivateKey)
+		_, pubKey := btcec.PrivKeyFromBytes(secretKeyBytes)
+		packet.Inputs[j].Bip32Derivation = []*btcpsbt.Bip32Derivation{{PubKey: pubKey.SerializeCompressed()}}
+	}
+
+	// only one of the two inputs has a share
+	require.NoError(t, AddInputECDHShare(packet, 0, shares[0]))
+	require.ErrorIs(t, ComputeOutputs(packet), ErrMissingECDHShare)
+
+	require.NoError(t, AddInputECDHShare(packet, 1, shares[1]))
+	require.NoError(t, ComputeOutputs(packet))
+	require.Equal(t, testCase.Expected.Outputs[0], hex.EncodeToString(packet.UnsignedTx.TxOut[0].PkScript[2:]))
+}
+
+func TestOutputInfo(t *testing.T) {
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, nil))
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{0x00, 0x14}))
+
+	packet, err := btcpsbt.NewFromUnsignedTx(tx)
+	require.NoError(t, err)
+
+	address, err := bip352.ParseAddress("sp1qqgste7k9hx0qftg6qmwlkqtwuy6cycyavzmzj85c6qdfhjdpdjtdgqjuexzk6murw56suy3e0rd2cgqvycxttddwsvgxe2usfpxumr70xc9pkqwv")
+	require.NoError(t, err)
+
+	var m uint32 = 0
+	require.NoError(t, SetOutputInfo(packet, 0, OutputInfo{ScanKey: address.ScanKey(), SpendKey: address.SpendKey(), Label: &m}))
+	require.ErrorIs(t, SetOutputInfo(packet, 2, OutputInfo{}), ErrInvalidOutputIndex)
+
+	info, err := GetOutputInfo(packet, 0)
+	require.NoError(t, err)
+	require.Equal(t, address.ScanKey(), info.ScanKey)
+	require.Equal(t, address.SpendKey(), info.SpendKey)
+	require.NotNil(t, info.Label)
+	require.Equal(t, m, *info.Label)
+
+	info, err = GetOutputInfo(packet, 1)
+	require.NoError(t, err)
+	require.Nil(t, info)
+
+	packet.Outputs[1].Unknowns = append(packet.Outputs[1].Unknowns, &btcpsbt.Unknown{Key: []byte{OutputSPV0InfoType}, Value: []byte{0x02}})
+	_, err = GetOutputInfo(packet, 1)
+	require.ErrorIs(t, err, ErrInvalidFieldLength)
+}
+
+func TestComputeOutputsInvalidProof(t *testing.T) {
+	testCase := loadSendingTestCases(t)[0].Sending[0]
+
+	tx := wire.NewMsgTx(2)
+	for _, vin := range testCase.Given.Vin {
+		hash, err := chainhash.NewHashFromStr(vin.Txid)
+		require.NoError(t, err)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, vin.Vout), nil, nil))
+	}
+	tx.AddTxOut(wire.NewTxOut(1000, nil))
+
+	packet, err := btcpsbt.NewFromUnsignedTx(tx)
+	require.NoError(t, err)
+
+	address, err := bip352.ParseAddress(testCase.Given.Recipients[0])
+	require.NoError(t, err)
+	require.NoError(t, SetOutputInfo(packet, 0, OutputInfo{ScanKey: address.ScanKey(), SpendKey: address.SpendKey()}))
+
+	var shares []ECDHShare
+	var secretKeys [][32]byte
+	for j, vin := range testCase.Given.Vin {
+		scriptPubKey, _ := hex.DecodeString(vin.Prevout.ScriptPubKey.Hex)
+		secretKeyBytes, _ := hex.DecodeString(vin.PrivateKey)
+		_, pubKey := btcec.PrivKeyFromBytes(secretKeyBytes)
+		secretKeys = append(secretKeys, utils.ConvertToFixedLength32(secretKeyBytes))
+
+		packet.Inputs[j].WitnessUtxo = wire.NewTxOut(10_000, scriptPubKey)
+		packet.Inputs[j].Bip32Derivation = []*btcpsbt.Bip32Derivation{{PubKey: pubKey.SerializeCompressed()}}
+
+		share, err := NewECDHShare(utils.ConvertToFixedLength32(secretKeyBytes), address.ScanKey(), &[32]byte{})
+		require.NoError(t, err)
+		require.True(t, share.Verify(bip352.PubKeyFromSecKey((*[32]byte)(secretKeyBytes))))
+		shares = append(shares, share)
+	}
+
+	// shares without a proof can not be checked and are rejected
+	for j := range shares {
+		require.NoError(t, AddInputECDHShare(packet, j, ECDHShare{ScanKey: shares[j].ScanKey, Share: shares[j].Share}))
+	}
+	require.ErrorIs(t, ComputeOutputs(packet), ErrMissingDLEQProof)
+
+	secretKeySum := bip352.RecursiveAddPrivateKeys(secretKeys)
+	globalShare, err := NewECDHShare(secretKeySum, address.ScanKey(), &[32]byte{})
+	require.NoError(t, err)
+	AddGlobalECDHShare(packet, ECDHShare{ScanKey: globalShare.ScanKey, Share: globalShare.Share})
+	require.ErrorIs(t, ComputeOutputs(packet), ErrMissingDLEQProof)
+	require.Empty(t, packet.UnsignedTx.TxOut[0].PkScript)
+
+	AddGlobalECDHShare(packet, globalShare)
+	require.NoError(t, ComputeOutputs(packet))
+	require.Equal(t, testCase.Expected.Outputs[0], hex.EncodeToString(packet.UnsignedTx.TxOut[0].PkScript[2:]))
+	packet.Unknowns = nil
+
+	// the shares are swapped, every proof is valid but not for the input it is attached to
+	require.NoError(t, AddInputECDHShare(packet, 0, shares[1]))
+	require.NoError(t, AddInputECDHShare(packet, 1, shares[0]))
+	require.ErrorIs(t, ComputeOutputs(packet), ErrInvalidDLEQProof)
+
+	require.NoError(t, AddInputECDHShare(packet, 0, shares[0]))
+	require.NoError(t, AddInputECDHShare(packet, 1, shares[1]))
+	require.NoError(t, ComputeOutputs(packet))
+	require.Equal(t, testCase.Expected.Outputs[0], hex.EncodeToString(packet.UnsignedTx.TxOut[0].PkScript[2:]))
+}
@@ -17,7 +17,7 @@ type FoundOutput struct {
 type Label struct {
 	PubKey  [33]byte `json:"pub_key"` // compressed pubKey of the label
 	Tweak   [32]byte `json:"tweak"`   // tweak/secKey to produce the labels pubKey
-	Address string   `json:"address"` // todo the corresponding address for the label, still needs a good API for instantiating with this data
+	Address string   `json:"address"` // the corresponding address for the label, filled by LabelManager
 	M       uint32   `json:"m"`
 }
 
@@ -38,6 +38,36 @@ func (l *Label) MarshalJSON() ([]byte, error) {
 	return json.Marshal(alias)
 }
 
+func (l *Label) UnmarshalJSON(data []byte) error {
+	var alias LabelJSON
+	err := json.Unmarshal(data, &alias)
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := hex.DecodeString(alias.PubKey)
+	if err != nil {
+		return err
+	}
+	if len(pubKey) != 33 {
+		return ErrInvalidPublicKey
+	}
+
+	tweak, err := hex.DecodeString(alias.Tweak)
+	if err != nil {
+		return err
+	}
+	if len(tweak) != 32 {
+		return ErrInvalidSecretKey
+	}
+
+	l.PubKey = utils.ConvertToFixedLength33(pubKey)
+	l.Tweak = utils.ConvertToFixedLength32(tweak)
+	l.Address = alias.Address
+	l.M = alias.M
+	return nil
+}
+
 // ReceiverScanTransaction
 // scanKey: scanning secretKey of the receiver
 // receiverSpendPubKey: spend pubKey of the receiver
@@ -62,6 +92,37 @@ func ReceiverScanTransaction(
 	)
 }
 
+// ReceiverScanTransactionWithSigner is ReceiverScanTransaction with the scan secret key behind an ECDHSigner.
+// See ReceiverScanTransaction for the other arguments.
+func ReceiverScanTransactionWithSigner(
+	scanSigner ECDHSigner,
+	receiverSpendPubKey *[33]byte,
+	labels []*Label,
+	txOutputs [][32]byte,
+	publicComponent *[33]byte,
+	inputHash *[32]byte,
+) ([]*FoundOutput, error) {
+	tweak := publicComponent
+	if inputHash != nil {
+		// tweak = input_hash * A_sum
+		var err error
+		tweak, err = CreateSharedSecret(publicComponent, inputHash, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sharedSecret, err := scanSigner.ECDH(tweak)
+	if err != nil {
+		return nil, err
+	}
+
+	// the scan key is not needed once the shared secret is known
+	return ReceiverScanTransactionWithSharedSecret(
+		[32]byte{}, receiverSpendPubKey, labels, txOutputs, &sharedSecret,
+	)
+}
+
 func ReceiverScanTransactionWithSharedSecret(
 	scanKey [32]byte,
 	receiverSpendPubKey *[33]byte,
@@ -69,98 +130,193 @@ func ReceiverScanTransactionWithSharedSecret(
 	txOutputs [][32]byte,
 	sharedSecret *[33]byte,
 ) (foundOutputs []*FoundOutput, err error) {
-	var k uint32 = 0
-	for {
-		var outputPubKey [32]byte
-		var tweak [32]byte
-		outputPubKey, tweak, err = CreateOutputPubKeyTweak(sharedSecret, receiverSpendPubKey, k)
-		if err != nil {
-			return nil, err
+	var matchLabel labelMatcher
+	if labels != nil {
+		matchLabel = func(labelPubKey [33]byte) *Label {
+			for _, label := range labels {
+				if bytes.Equal(labelPubKey[1:], label.PubKey[1:]) {
+					return label
+				}
+			}
+			return nil
 		}
+	}
+	return receiverScanTransaction(receiverSpendPubKey, matchLabel, txOutputs, sharedSecret)
+}
 
-		var found bool
+// ReceiverScanTransactionWithLabelSet is ReceiverScanTransactionWithSharedSecret with labels in a LabelSet.
+// Matching a label takes constant time, use it for wallets with many labels.
+func ReceiverScanTransactionWithLabelSet(
+	receiverSpendPubKey *[33]byte,
+	labelSet *LabelSet,
+	txOutputs [][32]byte,
+	sharedSecret *[33]byte,
+) ([]*FoundOutput, error) {
+	var matchLabel labelMatcher
+	if labelSet != nil && labelSet.Len() > 0 {
+		matchLabel = func(labelPubKey [33]byte) *Label {
+			label, _ := labelSet.Get(xOnlyKey(labelPubKey))
+			return label
+		}
+	}
+	return receiverScanTransaction(receiverSpendPubKey, matchLabel, txOutputs, sharedSecret)
+}
+
+// labelMatcher returns the label with the x-only key of labelPubKey, nil if there is none
+type labelMatcher func(labelPubKey [33]byte) *Label
+
+// labelCandidate is an output which can still be matched against the labels
+type labelCandidate struct {
+	output     [32]byte
+	index      int      // position in the transaction outputs
+	outputNeg  [33]byte // -output, the output lifted to the point with even y and negated
+	outputEven [33]byte // the output lifted to the point with even y
+}
+
+// receiverScanTransaction checks txOutputs for outputs of the receiver.
+// matchLabel can be nil if no labels should be checked.
+// At most kMax outputs are found, senders can not create more for one receiver.
+//
+// The outputs are indexed by their x-only key, an output without label is found with one lookup per k.
+// For labels the unmatched outputs are checked with output - P_k and -output - P_k per k,
+// where -P_k is computed once per k and the negated outputs once per transaction.
+// txOutputs is not modified.
+func receiverScanTransaction(
+	receiverSpendPubKey *[33]byte,
+	matchLabel labelMatcher,
+	txOutputs [][32]byte,
+	sharedSecret *[33]byte,
+) (foundOutputs []*FoundOutput, err error) {
+	// index of the first occurrence of every unmatched output
+	unmatched := make(map[[32]byte]int, len(txOutputs))
+	for i := len(txOutputs) - 1; i >= 0; i-- {
+		unmatched[txOutputs[i]] = i
+	}
+
+	var candidates []labelCandidate
+	if matchLabel != nil {
+		candidates = make([]labelCandidate, 0, len(txOutputs))
 		for i, txOutput := range txOutputs {
-			if bytes.Equal(outputPubKey[:], txOutput[:]) {
-				foundOutputs = append(foundOutputs, &FoundOutput{
-					Output:      txOutput,
-					SecKeyTweak: tweak,
-					Label:       nil,
-				})
-				// txOutputs = slices.Delete(txOutputs, i, i+1) // very slow
-				txOutputs = append(txOutputs[:i], txOutputs[i+1:]...)
-				found = true
-				k++
-				break // found the matching txOutput for outputPubKey, don't try the rest
-			}
+			candidate := labelCandidate{output: txOutput, index: i}
+			candidate.outputEven[0] = 0x02
+			copy(candidate.outputEven[1:], txOutput[:])
 
-			if labels == nil {
+			// anyone can create outputs that are not on the curve, they can not belong to the receiver
+			if validatePublicKey(candidate.outputEven) != nil {
 				continue
 			}
 
-			// now check the labels
-			var foundLabel *Label
-
-			prependedTxOutput := utils.ConvertToFixedLength33(append([]byte{0x02}, txOutput[:]...))
-			prependedOutputPubKey := utils.ConvertToFixedLength33(append([]byte{0x02}, outputPubKey[:]...))
-
-			// start with normal output
-			foundLabel, err = MatchLabels(prependedTxOutput, prependedOutputPubKey, labels)
+			candidate.outputNeg = candidate.outputEven
+			err = NegatePublicKey(&candidate.outputNeg)
 			if err != nil {
 				return nil, err
 			}
+			candidates = append(candidates, candidate)
+		}
+	}
 
-			// important: copy the tweak to avoid modifying the original tweak
-			var secKeyTweak [32]byte
-			copy(secKeyTweak[:], tweak[:])
+	for k := uint32(0); k < kMax && len(unmatched) > 0; k++ {
+		outputPubKey, tweak, err := CreateOutputPubKeyTweak(sharedSecret, receiverSpendPubKey, k)
+		if err != nil {
+			return nil, err
+		}
 
-			if foundLabel != nil {
-				err = AddPrivateKeys(&secKeyTweak, &foundLabel.Tweak) // labels have a modified tweak
-				if err != nil {
-					return nil, err
-				}
-				foundOutputs = append(foundOutputs, &FoundOutput{
-					Output:      txOutput,
-					SecKeyTweak: secKeyTweak,
-					Label:       foundLabel,
-				})
-				txOutputs = append(txOutputs[:i], txOutputs[i+1:]...)
-				found = true
-				k++
-				break
-			}
+		// the first output in transaction order is matched for k, either as P_k or as P_k plus a label.
+		// Labels only have to be checked for the outputs before a direct match.
+		directIndex, direct := unmatched[outputPubKey]
+		labelsBefore := len(txOutputs)
+		if direct {
+			labelsBefore = directIndex
+		}
 
-			// try the negated output for the label
-			err = NegatePublicKey(&prependedTxOutput)
+		var foundOutput *FoundOutput
+		if matchLabel != nil {
+			foundOutput, err = matchLabelCandidates(candidates, unmatched, labelsBefore, matchLabel, outputPubKey, tweak)
 			if err != nil {
 				return nil, err
 			}
+		}
+		if foundOutput == nil && direct {
+			foundOutput = &FoundOutput{
+				Output:      outputPubKey,
+				SecKeyTweak: tweak,
+				Label:       nil,
+			}
+		}
+		if foundOutput == nil {
+			break
+		}
+
+		delete(unmatched, foundOutput.Output)
+		foundOutputs = append(foundOutputs, foundOutput)
+	}
 
-			foundLabel, err = MatchLabels(prependedTxOutput, prependedOutputPubKey, labels)
+	return foundOutputs, nil
+}
+
+// matchLabelCandidates returns the first unmatched output before index end which is P_k plus a label,
+// nil if there is none
+func matchLabelCandidates(
+	candidates []labelCandidate,
+	unmatched map[[32]byte]int,
+	end int,
+	matchLabel labelMatcher,
+	outputPubKey [32]byte,
+	tweak [32]byte,
+) (*FoundOutput, error) {
+	// -P_k, subtraction is adding a negated value
+	var outputPubKeyNeg [33]byte
+	outputPubKeyNeg[0] = 0x02
+	copy(outputPubKeyNeg[1:], outputPubKey[:])
+	err := NegatePublicKey(&outputPubKeyNeg)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range candidates {
+		candidate := &candidates[i]
+		if candidate.index >= end {
+			break
+		}
+		if index, ok := unmatched[candidate.output]; !ok || index != candidate.index {
+			continue
+		}
+
+		// output - P_k, the output was created with the even point
+		labelPubKey, err := AddPublicKeys(&candidate.outputEven, &outputPubKeyNeg)
+		if err != nil {
+			return nil, err
+		}
+		foundLabel := matchLabel(labelPubKey)
+
+		if foundLabel == nil {
+			// -output - P_k, the output was created with the odd point
+			labelPubKey, err = AddPublicKeys(&candidate.outputNeg, &outputPubKeyNeg)
 			if err != nil {
 				return nil, err
 			}
-			if foundLabel != nil {
-				err = AddPrivateKeys(&secKeyTweak, &foundLabel.Tweak) // labels have a modified tweak
-				if err != nil {
-					return nil, err
-				}
-				foundOutputs = append(foundOutputs, &FoundOutput{
-					Output:      utils.ConvertToFixedLength32(prependedTxOutput[1:]),
-					SecKeyTweak: secKeyTweak,
-					Label:       foundLabel,
-				})
-				txOutputs = append(txOutputs[:i], txOutputs[i+1:]...)
-				found = true
-				k++
-				break
-			}
+			foundLabel = matchLabel(labelPubKey)
 		}
 
-		if !found {
-			break
+		if foundLabel == nil {
+			continue
+		}
+
+		// labels have a modified tweak
+		secKeyTweak := tweak
+		err = AddPrivateKeys(&secKeyTweak, &foundLabel.Tweak)
+		if err != nil {
+			return nil, err
 		}
+
+		return &FoundOutput{
+			Output:      candidate.output,
+			SecKeyTweak: secKeyTweak,
+			Label:       foundLabel,
+		}, nil
 	}
-	return foundOutputs, nil
+
+	return nil, nil
 }
 
 func MatchLabels(txOutput, pk [33]byte, labels []*Label) (*Label, error) {
@@ -11,7 +11,6 @@ import (
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/setavenger/blindbit-lib/utils"
-	golibsecp256k1 "github.com/setavenger/go-libsecp256k1"
 	"github.com/stretchr/testify/require"
 )
 
@@ -39,8 +38,8 @@ func TestReceiverScanTransaction(t *testing.T) {
 			secKeySpend := utils.ConvertToFixedLength32(secKeySpendBytes)
 
 			// public keys
-			scanPubKey := golibsecp256k1.PubKeyFromSecKey(&secKeyScan)
-			spendPubKey := golibsecp256k1.PubKeyFromSecKey(&secKeySpend)
+			scanPubKey := PubKeyFromSecKey(&secKeyScan)
+			spendPubKey := PubKeyFromSecKey(&secKeySpend)
 
 			// compute label data
 			var labels []*Label
@@ -54,7 +53,7 @@ func TestReceiverScanTransaction(t *testing.T) {
 				labeledAddress, err = CreateLabeledAddress(
 					scanPubKey,
 					spendPubKey,
-					true,
+					Mainnet,
 					0,
 					&secKeyScan,
 					labelInt,
@@ -72,7 +71,7 @@ func TestReceiverScanTransaction(t *testing.T) {
 			address, err = CreateAddress(
 				scanPubKey,
 				spendPubKey,
-				true,
+				Mainnet,
 				0,
 			)
 			require.NoError(t, err)
@@ -113,6 +112,8 @@ func TestReceiverScanTransaction(t *testing.T) {
 				continue
 			}
 
+			publicComponentBefore, inputHashBefore := *publicComponent, *inputHash
+
 			var foundOutputs []*FoundOutput
 			foundOutputs, err = ReceiverScanTransaction(
 				secKeyScan,
@@ -124,6 +125,10 @@ func TestReceiverScanTransaction(t *testing.T) {
 			)
 			require.NoError(t, err)
 
+			// the tweak data of the caller is not modified
+			require.Equal(t, publicComponentBefore, *publicComponent)
+			require.Equal(t, inputHashBefore, *inputHash)
+
 			if len(foundOutputs) != len(testCase.Expected.Outputs) {
 				t.Errorf("Error: wrong number outputs found %d != %d", len(foundOutputs), len(testCase.Expected.Outputs))
 				return
@@ -204,8 +209,8 @@ func BenchmarkReceiverScanTransaction(b *testing.B) {
 	require.NoError(b, err)
 
 	secKeySpend := utils.ConvertToFixedLength32(secKeySpendBytes)
-	scanPub := golibsecp256k1.PubKeyFromSecKey(&secKeyScan)
-	spendPub := golibsecp256k1.PubKeyFromSecKey(&secKeySpend)
+	scanPub := PubKeyFromSecKey(&secKeyScan)
+	spendPub := PubKeyFromSecKey(&secKeySpend)
 
 	// compute label data
 	var labels []*Label
@@ -219,7 +224,7 @@ func BenchmarkReceiverScanTransaction(b *testing.B) {
 		labeledAddress, err = CreateLabeledAddress(
 			scanPub,
 			spendPub,
-			true,
+			Mainnet,
 			0,
 			&secKeyScan,
 			labelInt,
@@ -333,3 +338,204 @@ func ExtractTweak(caseDataVins []VinReceiveTestCase) (*[33]byte, *[32]byte, erro
 
 	return sumPublicKeys, inputHash, err
 }
+
+// fuzzLabels are the labels of the test vectors
+var fuzzLabels = []uint32{0, 1, 2, 3, 1001337}
+
+func FuzzReceiverScanTransactionWithSharedSecret(f *testing.F) {
+	caseData, err := LoadFullCaseData(f)
+	require.NoError(f, err)
+
+	for _, cases := range caseData {
+		for _, testCase := range cases.Receiving {
+			secKeyScan, secKeySpend := testVectorKeys(f, testCase.Given.KeyMaterial.ScanPrivKey, testCase.Given.KeyMaterial.SpendPrivKey)
+
+			var outputs []byte
+			for _, output := range testCase.Given.Outputs {
+				outputBytes, _ := hex.DecodeString(output)
+				outputs = append(outputs, outputBytes...)
+			}
+
+			publicComponent, inputHash, err := ExtractTweak(testCase.Given.Vin)
+			if err != nil {
+				continue
+			}
+			sharedSecret, err := CreateSharedSecret(publicComponent, &secKeyScan, inputHash)
+			require.NoError(f, err)
+
+			f.Add(secKeyScan[:], PubKeyFromSecKey(&secKeySpend)[:], sharedSecret[:], outputs)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, scanSecKey, spendPubKey, sharedSecret, outputs []byte) {
+		if len(scanSecKey) != 32 || len(spendPubKey) != 33 || len(sharedSecret) != 33 {
+			return
+		}
+		scanSecKeyFixed := utils.ConvertToFixedLength32(scanSecKey)
+		spendPubKeyFixed := utils.ConvertToFixedLength33(spendPubKey)
+		sharedSecretFixed := utils.ConvertToFixedLength33(sharedSecret)
+
+		var labels []*Label
+		for _, m := range fuzzLabels {
+			label, err := CreateLabel(&scanSecKeyFixed, m)
+			if err != nil {
+				return
+			}
+			labels = append(labels, &label)
+		}
+
+		var txOutputs [][32]byte
+		for i := 0; i+32 <= len(outputs); i += 32 {
+			txOutputs = append(txOutputs, utils.ConvertToFixedLength32(outputs[i:i+32]))
+		}
+		outputSet := make(map[[32]byte]struct{})
+		for _, txOutput := range txOutputs {
+			outputSet[txOutput] = struct{}{}
+		}
+
+		// scanning never panics, found outputs are always outputs of the transaction
+		foundOutputs, err := ReceiverScanTransactionWithSharedSecret(
+			scanSecKeyFixed, &spendPubKeyFixed, labels, txOutputs, &sharedSecretFixed,
+		)
+		if err != nil {
+			return
+		}
+		require.LessOrEqual(t, len(foundOutputs), len(txOutputs))
+		for _, foundOutput := range foundOutputs {
+			_, ok := outputSet[foundOutput.Output]
+			require.True(t, ok)
+		}
+	})
+}
+
+func TestReceiverScanTransactionKMax(t *testing.T) {
+	scanSecKey, spendSecKey := testKeys()
+	spendPubKey := PubKeyFromSecKey(&spendSecKey)
+
+	vins := kMaxTestVins(t)
+	var secretKeys [][32]byte
+	for _, vin := range vins {
+		secretKeys = append(secretKeys, *vin.SecretKey)
+	}
+	secretKeySum := RecursiveAddPrivateKeys(secretKeys)
+	publicKeySum := PubKeyFromSecKey(&secretKeySum)
+	inputHash, err := ComputeInputHash(vins, publicKeySum)
+	require.NoError(t, err)
+
+	// one output more than allowed, only a misbehaving sender creates it
+	sharedSecret, err := CreateSharedSecret(PubKeyFromSecKey(&scanSecKey), &secretKeySum, inputHash)
+	require.NoError(t, err)
+	txOutputs := make([][32]byte, kMax+1)
+	for k := range txOutputs {
+		txOutputs[k], err = CreateOutputPubKey(*sharedSecret, *spendPubKey, uint32(k))
+		require.NoError(t, err)
+	}
+
+	foundOutputs, err := ReceiverScanTransaction(scanSecKey, spendPubKey, nil, txOutputs, publicKeySum, inputHash)
+	require.NoError(t, err)
+	require.Len(t, foundOutputs, int(kMax))
+	for k, foundOutput := range foundOutputs {
+		require.Equal(t, txOutputs[k], foundOutput.Output)
+	}
+}
+
+// receiverTestOutputs returns the outputs of a transaction with n outputs for the receiver with spendSecKey.
+// The last two outputs belong to the receiver, k=0 without label and k=1 with label.
+func receiverTestOutputs(
+	tb testing.TB,
+	n int,
+	sharedSecret [33]byte,
+	spendSecKey [32]byte,
+	label *Label,
+) [][32]byte {
+	spendPubKey := PubKeyFromSecKey(&spendSecKey)
+
+	txOutputs := make([][32]byte, 0, n)
+	for i := 0; i < n-2; i++ {
+		secKey := sha256.Sum256([]byte(fmt.Sprintf("output %d", i)))
+		txOutputs = append(txOutputs, [32]byte(PubKeyFromSecKey(&secKey)[1:]))
+	}
+
+	output, err := CreateOutputPubKey(sharedSecret, *spendPubKey, 0)
+	require.NoError(tb, err)
+	txOutputs = append(txOutputs, output)
+
+	labeledSpendPubKey, err := CreateLabelledSpendPubKey(spendPubKey, &label.PubKey)
+	require.NoError(tb, err)
+	output, err = CreateOutputPubKey(sharedSecret, labeledSpendPubKey, 1)
+	require.NoError(tb, err)
+	txOutputs = append(txOutputs, output)
+
+	return txOutputs
+}
+
+func TestReceiverScanTransactionOutputsUnmodified(t *testing.T) {
+	scanSecKey, spendSecKey := testKeys()
+	sharedSecret := *PubKeyFromSecKey(&scanSecKey)
+	label, err := CreateLabel(&scanSecKey, 0)
+	require.NoError(t, err)
+
+	txOutputs := receiverTestOutputs(t, 10, sharedSecret, spendSecKey, &label)
+	txOutputs[0], txOutputs[8] = txOutputs[8], txOutputs[0]
+	txOutputsCopy := append([][32]byte{}, txOutputs...)
+
+	foundOutputs, err := ReceiverScanTransactionWithSharedSecret(
+		scanSecKey, PubKeyFromSecKey(&spendSecKey), []*Label{&label}, txOutputs, &sharedSecret,
+	)
+	require.NoError(t, err)
+	require.Len(t, foundOutputs, 2)
+	require.Equal(t, txOutputs[0], foundOutputs[0].Output)
+	require.Nil(t, foundOutputs[0].Label)
+	require.Equal(t, txOutputs[9], foundOutputs[1].Output)
+	require.Equal(t, &label, foundOutputs[1].Label)
+
+	require.Equal(t, txOutputsCopy, txOutputs)
+}
+
+func TestReceiverScanTransactionOffCurveOutput(t *testing.T) {
+	scanSecKey, spendSecKey := testKeys()
+	sharedSecret := *PubKeyFromSecKey(&scanSecKey)
+	label, err := CreateLabel(&scanSecKey, 0)
+	require.NoError(t, err)
+
+	// x = 0 is not on the curve, the output must not abort the label matching
+	var offCurve [32]byte
+	require.ErrorIs(t, validatePublicKey([33]byte{0x02}), ErrInvalidPublicKey)
+	txOutputs := append([][32]byte{offCurve}, receiverTestOutputs(t, 4, sharedSecret, spendSecKey, &label)...)
+
+	foundOutputs, err := ReceiverScanTransactionWithSharedSecret(
+		scanSecKey, PubKeyFromSecKey(&spendSecKey), []*Label{&label}, txOutputs, &sharedSecret,
+	)
+	require.NoError(t, err)
+	require.Len(t, foundOutputs, 2)
+}
+
+func BenchmarkReceiverScanTransactionOutputs(b *testing.B) {
+	scanSecKey, spendSecKey := testKeys()
+	spendPubKey := PubKeyFromSecKey(&spendSecKey)
+	sharedSecret := *PubKeyFromSecKey(&scanSecKey)
+	label, err := CreateLabel(&scanSecKey, 0)
+	require.NoError(b, err)
+	labelSet := NewLabelSet(&label)
+
+	for _, n := range []int{2, 200, 2000} {
+		txOutputs := receiverTestOutputs(b, n, sharedSecret, spendSecKey, &label)
+
+		b.Run(fmt.Sprintf("outputs-%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				// only the output without label is found
+				foundOutputs, err := ReceiverScanTransactionWithLabelSet(spendPubKey, nil, txOutputs, &sharedSecret)
+				require.NoError(b, err)
+				require.Len(b, foundOutputs, 1)
+			}
+		})
+
+		b.Run(fmt.Sprintf("outputs-%d-labels", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				foundOutputs, err := ReceiverScanTransactionWithLabelSet(spendPubKey, labelSet, txOutputs, &sharedSecret)
+				require.NoError(b, err)
+				require.Len(b, foundOutputs, 2)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package bip352
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// ScanItem is a transaction to be scanned by a BatchScanner
+type ScanItem struct {
+	Txid    chainhash.Hash
+	Tweak   [33]byte   // A_sum·input_hash, see ComputeTweak
+	Outputs [][32]byte // x-only keys of the taproot outputs
+}
+
+// BatchScanner scans many transactions in parallel.
+// The shared secret derivation dominates the cost of scanning and is spread over a pool of workers.
+type BatchScanner struct {
+	scanSecKey  [32]byte
+	spendPubKey [33]byte
+	labelSet    *LabelSet
+	workers     int
+}
+
+// NewBatchScanner creates a BatchScanner for the receiver.
+// labelSet can be nil if no labels should be checked, it must not be modified while scanning.
+// workers <= 0 uses one worker per CPU.
+func NewBatchScanner(scanSecKey [32]byte, spendPubKey [33]byte, labelSet *LabelSet, workers int) *BatchScanner {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	return &BatchScanner{
+		scanSecKey:  scanSecKey,
+		spendPubKey: spendPubKey,
+		labelSet:    labelSet,
+		workers:     workers,
+	}
+}
+
+// Scan scans the items received on items until the channel is closed.
+// Returns the found outputs keyed by txid, transactions without found outputs are omitted.
+// Scanning stops at the first error or when ctx is cancelled.
+func (s *BatchScanner) Scan(ctx context.Context, items <-chan ScanItem) (map[chainhash.Hash][]*FoundOutput, error) {
+	scanCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		results  = make(map[chainhash.Hash][]*FoundOutput)
+		wg       sync.WaitGroup
+	)
+
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+		cancel()
+	}
+
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var item ScanItem
+				var ok bool
+				select {
+				case <-scanCtx.Done():
+					return
+				case item, ok = <-items:
+					if !ok {
+						return
+					}
+				}
+
+				foundOutputs, err := s.ScanItem(item)
+				if err != nil {
+					fail(fmt.Errorf("tx %s: %w", item.Txid, err))
+					return
+				}
+				if len(foundOutputs) == 0 {
+					continue
+				}
+
+				mu.Lock()
+				results[item.Txid] = foundOutputs
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	// the items might not have been scanned completely
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ScanItems is Scan for a slice of items
+func (s *BatchScanner) ScanItems(ctx context.Context, items []ScanItem) (map[chainhash.Hash][]*FoundOutput, error) {
+	// stops the producer if Scan returns early on an error
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	itemChan := make(chan ScanItem)
+	go func() {
+		defer close(itemChan)
+		for _, item := range items {
+			select {
+			case itemChan <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return s.Scan(ctx, itemChan)
+}
+
+// ScanItem scans a single item, this is the sequential path used by the workers
+func (s *BatchScanner) ScanItem(item ScanItem) ([]*FoundOutput, error) {
+	if len(item.Outputs) == 0 {
+		return nil, nil
+	}
+
+	sharedSecret, err := CreateSharedSecret(&item.Tweak, &s.scanSecKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	spendPubKey := s.spendPubKey
+	return ReceiverScanTransactionWithLabelSet(&spendPubKey, s.labelSet, item.Outputs, sharedSecret)
+}
@@ -0,0 +1,144 @@
+package bip352
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchScanner(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	// case with labels and multiple outputs
+	testCase := caseData[17].Receiving[0]
+	secKeyScan, secKeySpend := testVectorKeys(t, testCase.Given.KeyMaterial.ScanPrivKey, testCase.Given.KeyMaterial.SpendPrivKey)
+	spendPubKey := PubKeyFromSecKey(&secKeySpend)
+
+	labelSet := NewLabelSet()
+	for _, m := range testCase.Given.Labels {
+		label, err := CreateLabel(&secKeyScan, m)
+		require.NoError(t, err)
+		labelSet.Add(&label)
+	}
+
+	tx, fetcher := txFromTestVins(t, testCase.Given.Vin, testCase.Given.Outputs)
+	tweak, err := ComputeTweak(tx, fetcher)
+	require.NoError(t, err)
+
+	expected := make(map[chainhash.Hash]int)
+	var items []ScanItem
+	for i := 0; i < 50; i++ {
+		txid := chainhash.HashH([]byte(fmt.Sprintf("tx%d", i)))
+		outputs := TaprootOutputs(tx)
+		if i%3 == 0 {
+			// unrelated transaction
+			unrelated := sha256.Sum256(txid[:])
+			outputs = [][32]byte{[32]byte(PubKeyFromSecKey(&unrelated)[1:])}
+		} else {
+			expected[txid] = len(testCase.Expected.Outputs)
+		}
+		items = append(items, ScanItem{Txid: txid, Tweak: *tweak, Outputs: outputs})
+	}
+
+	for _, workers := range []int{0, 1, 4} {
+		scanner := NewBatchScanner(secKeyScan, *spendPubKey, labelSet, workers)
+		results, err := scanner.ScanItems(context.Background(), items)
+		require.NoError(t, err)
+		require.Len(t, results, len(expected))
+
+		for txid, foundOutputs := range results {
+			require.Len(t, foundOutputs, expected[txid])
+			for i, foundOutput := range foundOutputs {
+				require.Equal(t, testCase.Expected.Outputs[i].PubKey, hex.EncodeToString(foundOutput.Output[:]))
+				require.Equal(t, testCase.Expected.Outputs[i].PrivKeyTweak, hex.EncodeToString(foundOutput.SecKeyTweak[:]))
+			}
+		}
+	}
+
+	// the outputs of the items are not modified
+	require.Equal(t, TaprootOutputs(tx), items[1].Outputs)
+}
+
+func TestBatchScannerErrors(t *testing.T) {
+	scanSecKey, spendSecKey := testKeys()
+	scanner := NewBatchScanner(scanSecKey, *PubKeyFromSecKey(&spendSecKey), nil, 2)
+
+	// invalid tweaks abort the scan
+	items := []ScanItem{{Tweak: [33]byte{0x02}, Outputs: [][32]byte{{}}}}
+	_, err := scanner.ScanItems(context.Background(), items)
+	require.Error(t, err)
+
+	// an unclosed channel is abandoned on cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = scanner.Scan(ctx, make(chan ScanItem))
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestBatchScannerErrorStopsProducer(t *testing.T) {
+	scanSecKey, spendSecKey := testKeys()
+	scanner := NewBatchScanner(scanSecKey, *PubKeyFromSecKey(&spendSecKey), nil, 2)
+
+	// more items than workers, the first one fails
+	items := benchScanItems(50)
+	items[0].Tweak = [33]byte{0x02}
+
+	goroutines := runtime.NumGoroutine()
+	_, err := scanner.ScanItems(context.Background(), items)
+	require.Error(t, err)
+
+	// the goroutine feeding the items exits as well
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+}
+
+// benchScanItems creates n transactions with random tweaks and two outputs
+func benchScanItems(n int) []ScanItem {
+	items := make([]ScanItem, n)
+	for i := range items {
+		secKey := sha256.Sum256([]byte(fmt.Sprintf("tweak%d", i)))
+		items[i] = ScanItem{
+			Txid:    chainhash.HashH(secKey[:]),
+			Tweak:   *PubKeyFromSecKey(&secKey),
+			Outputs: [][32]byte{sha256.Sum256(secKey[:1]), sha256.Sum256(secKey[:2])},
+		}
+	}
+	return items
+}
+
+func BenchmarkBatchScanner(b *testing.B) {
+	scanSecKey, spendSecKey := testKeys()
+	spendPubKey := PubKeyFromSecKey(&spendSecKey)
+	items := benchScanItems(2000)
+
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, item := range items {
+				tweak := item.Tweak
+				_, err := ReceiverScanTransaction(scanSecKey, spendPubKey, nil, item.Outputs, &tweak, nil)
+				require.NoError(b, err)
+			}
+		}
+	})
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			scanner := NewBatchScanner(scanSecKey, *spendPubKey, nil, workers)
+			for i := 0; i < b.N; i++ {
+				_, err := scanner.ScanItems(context.Background(), items)
+				require.NoError(b, err)
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ type Recipient struct {
 	ScanPubKey           *btcec.PublicKey
 	SpendPubKey          *btcec.PublicKey
 	Output               [32]byte // the resulting taproot x-only output
+	K                    uint32   // index of the output within the group of the scan key, set together with Output
 	Amount               uint64
 	Data                 map[string]any // in order to allocate data to a recipient that needs to be known after handling
 }
@@ -22,9 +23,8 @@ vins: has to include the txids, vouts and secretKeys
 NOTE: if checkVins is set to true the vins should include the necessary data in order to categorise them
 i.e. (scriptpubkey andOr witness andOr scriptSig)
 */
-func SenderCreateOutputs(recipients []*Recipient, vins []*Vin, mainnet bool, checkVins bool) error {
+func SenderCreateOutputs(recipients []*Recipient, vins []*Vin, network Network, checkVins bool) error {
 	var err error
-	var secretKeys [][32]byte
 
 	// first simple alias
 	var vinsSharedDerivation = vins
@@ -43,10 +43,31 @@ func SenderCreateOutputs(recipients []*Recipient, vins []*Vin, mainnet bool, che
 		}
 	}
 
+	return senderCreateOutputs(recipients, vins, vinsSharedDerivation, network)
+}
+
+// senderCreateOutputs computes the outputs for the recipients.
+// vins: all inputs of the transaction, used for the input_hash
+// vinsSharedDerivation: the eligible inputs, their secret keys are used for the shared secret
+func senderCreateOutputs(recipients []*Recipient, vins, vinsSharedDerivation []*Vin, network Network) error {
 	if len(vinsSharedDerivation) == 0 {
 		return ErrNoEligibleVins
 	}
 
+	// the receiver will not scan transactions spending future segwit versions
+	if spendsSegwitVersionAboveOne(vins) {
+		return ErrSegwitVersionUnsupported
+	}
+
+	// inputs that are controlled by a signer have to provide ECDH shares
+	for _, vin := range vinsSharedDerivation {
+		if vin.SecretKey == nil {
+			return senderCreateOutputsWithSigners(recipients, vins, vinsSharedDerivation, network)
+		}
+	}
+
+	var secretKeys [][32]byte
+
 	// negate keys if necessary before summing them; only uses eligible inputs
 	for _, vin := range vinsSharedDerivation {
 		interim := *vin.SecretKey
@@ -71,9 +92,137 @@ func SenderCreateOutputs(recipients []*Recipient, vins []*Vin, mainnet bool, che
 		return err
 	}
 
-	// extract the pubKeys from the SP address
+	err = decodeRecipients(recipients, network)
+	if err != nil {
+		return err
+	}
+
+	return createGroupOutputs(recipients, func(receiverScanPubKey [33]byte) (*[33]byte, error) {
+		return CreateSharedSecret(&receiverScanPubKey, &secretKeySum, inputHash)
+	})
+}
+
+/*
+SenderCreateOutputsFromShares
+computes the outputs from ECDH shares instead of secret keys.
+This is needed when the inputs are controlled by different parties or the keys live in hardware signers.
+
+recipients: must include result will be stored in the recipients.
+vins: all inputs of the transaction with txids and vouts.
+The eligible inputs must have the PublicKey set, e.g. from ExtractPubKey; inputs without a PublicKey are only used for the input_hash.
+For taproot inputs the even public key is used.
+shares: maps the scan pubKey of every recipient to the shares a_i·B_scan of all eligible inputs.
+An aggregated share a_sum·B_scan can be passed as the only share.
+The secret keys of taproot inputs with an odd public key have to be negated before computing the share.
+*/
+func SenderCreateOutputsFromShares(
+	recipients []*Recipient,
+	vins []*Vin,
+	shares map[[33]byte][][33]byte,
+	network Network,
+) error {
+	var pubKeys [][33]byte
+	for _, vin := range vins {
+		if vin.PublicKey == nil {
+			continue
+		}
+		pubKey := *vin.PublicKey
+		if vin.Taproot {
+			pubKey[0] = 0x02
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	return senderCreateOutputsFromShares(recipients, vins, pubKeys, network, func(receiverScanPubKey [33]byte) ([][33]byte, error) {
+		return shares[receiverScanPubKey], nil
+	})
+}
+
+// senderCreateOutputsWithSigners computes a share for every eligible vin with its secret key or signer
+func senderCreateOutputsWithSigners(recipients []*Recipient, vins, vinsSharedDerivation []*Vin, network Network) error {
+	var pubKeys [][33]byte
+	for _, vin := range vinsSharedDerivation {
+		pubKey, err := signingPubKey(vin)
+		if err != nil {
+			return err
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	return senderCreateOutputsFromShares(recipients, vins, pubKeys, network, func(receiverScanPubKey [33]byte) ([][33]byte, error) {
+		var shares [][33]byte
+		for _, vin := range vinsSharedDerivation {
+			share, err := ecdhShare(vin, receiverScanPubKey)
+			if err != nil {
+				return nil, err
+			}
+			shares = append(shares, share)
+		}
+		return shares, nil
+	})
+}
+
+// senderCreateOutputsFromShares
+// pubKeys: public keys of the eligible inputs
+// sharesFn: returns the shares of the eligible inputs for a scan pubKey
+func senderCreateOutputsFromShares(
+	recipients []*Recipient,
+	vins []*Vin,
+	pubKeys [][33]byte,
+	network Network,
+	sharesFn func(receiverScanPubKey [33]byte) ([][33]byte, error),
+) error {
+	if len(pubKeys) == 0 {
+		return ErrNoEligibleVins
+	}
+
+	if spendsSegwitVersionAboveOne(vins) {
+		return ErrSegwitVersionUnsupported
+	}
+
+	publicKeySum, err := SumPublicKeys(pubKeys)
+	if err != nil {
+		return err
+	}
+
+	inputHash, err := ComputeInputHash(vins, publicKeySum)
+	if err != nil {
+		return err
+	}
+
+	err = decodeRecipients(recipients, network)
+	if err != nil {
+		return err
+	}
+
+	return createGroupOutputs(recipients, func(receiverScanPubKey [33]byte) (*[33]byte, error) {
+		scanKeyShares, err := sharesFn(receiverScanPubKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(scanKeyShares) == 0 {
+			return nil, ErrECDHShareMissing
+		}
+
+		shareSum, err := SumPublicKeys(scanKeyShares)
+		if err != nil {
+			return nil, err
+		}
+
+		// shared_secret = input_hash * sum(a_i * B_scan)
+		return CreateSharedSecret(shareSum, inputHash, nil)
+	})
+}
+
+// decodeRecipients extracts the pubKeys from the SP addresses.
+// Recipients without an address must have both pubKeys set.
+func decodeRecipients(recipients []*Recipient, network Network) error {
 	for _, recipient := range recipients {
-		scanPubKeyBytes, spendPubKeyBytes, err := DecodeSilentPaymentAddressToKeys(recipient.SilentPaymentAddress, mainnet)
+		if recipient.SilentPaymentAddress == "" && recipient.ScanPubKey != nil && recipient.SpendPubKey != nil {
+			continue
+		}
+
+		scanPubKeyBytes, spendPubKeyBytes, err := DecodeSilentPaymentAddressToKeys(recipient.SilentPaymentAddress, network)
 		if err != nil {
 			return err
 		}
@@ -89,13 +238,28 @@ func SenderCreateOutputs(recipients []*Recipient, vins []*Vin, mainnet bool, che
 		recipient.ScanPubKey, recipient.SpendPubKey = scanPubKey, spendPubKey
 	}
 
-	groups := matchRecipients(recipients)
+	return nil
+}
 
-	for receiverScanPubKey, groupRecipients := range groups {
-		var secretCopy [32]byte
-		copy(secretCopy[:], secretKeySum[:])
+// createGroupOutputs groups the recipients by scan pubKey and derives the outputs of every group
+// from the shared secret returned by sharedSecretFn.
+// Groups are processed in the order of their first recipient, within a group k follows the order of the recipients.
+func createGroupOutputs(
+	recipients []*Recipient,
+	sharedSecretFn func(receiverScanPubKey [33]byte) (*[33]byte, error),
+) error {
+	groups, order := matchRecipients(recipients)
 
-		sharedSecret, err := CreateSharedSecret(&receiverScanPubKey, &secretCopy, inputHash)
+	// receivers stop scanning after kMax outputs, further outputs would not be found
+	for _, groupRecipients := range groups {
+		if len(groupRecipients) > int(kMax) {
+			return ErrTooManyGroupOutputs
+		}
+	}
+
+	for _, receiverScanPubKey := range order {
+		groupRecipients := groups[receiverScanPubKey]
+		sharedSecret, err := sharedSecretFn(receiverScanPubKey)
 		if err != nil {
 			return err
 		}
@@ -107,6 +271,7 @@ func SenderCreateOutputs(recipients []*Recipient, vins []*Vin, mainnet bool, che
 				return err
 			}
 			recipient.Output = outputPubKey
+			recipient.K = k
 			k++
 		}
 	}
@@ -129,11 +294,16 @@ func NegateSecretKey(secretKey [32]byte) [32]byte {
 	return sk.Key.Negate().Bytes()
 }
 
-func matchRecipients(recipients []*Recipient) map[[33]byte][]*Recipient {
-	matches := make(map[[33]byte][]*Recipient)
+// matchRecipients groups the recipients by scan pubKey.
+// order contains the scan pubKeys in the order of their first recipient.
+func matchRecipients(recipients []*Recipient) (matches map[[33]byte][]*Recipient, order [][33]byte) {
+	matches = make(map[[33]byte][]*Recipient)
 	for _, recipient := range recipients {
 		scanKey := utils.ConvertToFixedLength33(recipient.ScanPubKey.SerializeCompressed())
+		if _, ok := matches[scanKey]; !ok {
+			order = append(order, scanKey)
+		}
 		matches[scanKey] = append(matches[scanKey], recipient)
 	}
-	return matches
+	return matches, order
 }
@@ -1,12 +1,14 @@
 package bip352
 
 import (
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/setavenger/blindbit-lib/utils"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSenderCreateOutputs(t *testing.T) {
@@ -75,7 +77,7 @@ func TestSenderCreateOutputs(t *testing.T) {
 				})
 			}
 
-			err = SenderCreateOutputs(recipients, vins, true, false)
+			err = SenderCreateOutputs(recipients, vins, Mainnet, false)
 			if err != nil {
 				t.Errorf("Error: %s", err)
 				return
@@ -177,7 +179,7 @@ func TestSenderCreateOutputsWithVinCheck(t *testing.T) {
 				})
 			}
 
-			err = SenderCreateOutputs(recipients, vins, true, true)
+			err = SenderCreateOutputs(recipients, vins, Mainnet, true)
 			if err != nil {
 				if cases.Comment == "No valid inputs, sender generates no outputs" && errors.Is(err, ErrNoEligibleVins) {
 					continue
@@ -209,3 +211,173 @@ func TestSenderCreateOutputsWithVinCheck(t *testing.T) {
 }
 
 // todo write test to check that stored data in recipient stays there
+
+func TestSenderCreateOutputsFromShares(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	for i, cases := range caseData {
+		for _, testCase := range cases.Sending {
+			var vins []*Vin
+			var secretKeys [][32]byte
+
+			for _, vin := range testCase.Given.Vin {
+				txid, err := hex.DecodeString(vin.Txid)
+				require.NoError(t, err)
+				secKey, err := hex.DecodeString(vin.PrivateKey)
+				require.NoError(t, err)
+				scriptPubKey, err := hex.DecodeString(vin.Prevout.ScriptPubKey.Hex)
+				require.NoError(t, err)
+				scriptSig, err := hex.DecodeString(vin.ScriptSig)
+				require.NoError(t, err)
+				witness, _ := hex.DecodeString(vin.Txinwitness)
+				var witnessScript [][]byte
+				if len(witness) > 0 {
+					witnessScript, err = ParseWitnessScript(witness)
+					require.NoError(t, err)
+				}
+
+				vinInner := &Vin{
+					Txid:         utils.ConvertToFixedLength32(txid),
+					Vout:         vin.Vout,
+					ScriptPubKey: scriptPubKey,
+					ScriptSig:    scriptSig,
+					Witness:      witnessScript,
+					Taproot:      IsP2TR(scriptPubKey),
+				}
+				vins = append(vins, vinInner)
+
+				// only eligible inputs provide a public key and a share
+				if _, utxoType := ExtractPubKey(vinInner); utxoType == Unknown {
+					continue
+				}
+
+				secretKey := utils.ConvertToFixedLength32(secKey)
+				if vinInner.Taproot {
+					secretKey = checkToNegate(secretKey)
+				}
+				vinInner.PublicKey = PubKeyFromSecKey(&secretKey)
+				secretKeys = append(secretKeys, secretKey)
+			}
+
+			var recipients []*Recipient
+			shares := make(map[[33]byte][][33]byte)
+			for _, address := range testCase.Given.Recipients {
+				recipients = append(recipients, &Recipient{SilentPaymentAddress: address})
+
+				scanPubKey, _, err := DecodeSilentPaymentAddressToKeys(address, Mainnet)
+				require.NoError(t, err)
+				if _, ok := shares[scanPubKey]; ok {
+					continue
+				}
+
+				for _, secretKey := range secretKeys {
+					scanPubKeyCopy := scanPubKey
+					share, err := CreateSharedSecret(&scanPubKeyCopy, &secretKey, nil)
+					require.NoError(t, err)
+					shares[scanPubKey] = append(shares[scanPubKey], *share)
+				}
+
+				// alternate between per input shares and an aggregated share
+				if i%2 == 0 && len(shares[scanPubKey]) > 0 {
+					aggregated, err := SumPublicKeys(shares[scanPubKey])
+					require.NoError(t, err)
+					shares[scanPubKey] = [][33]byte{*aggregated}
+				}
+			}
+
+			err = SenderCreateOutputsFromShares(recipients, vins, shares, Mainnet)
+			if errors.Is(err, ErrNoEligibleVins) {
+				require.Empty(t, testCase.Expected.Outputs, cases.Comment)
+				continue
+			}
+			require.NoError(t, err, cases.Comment)
+
+			for _, recipient := range recipients {
+				require.Contains(t, testCase.Expected.Outputs, hex.EncodeToString(recipient.Output[:]), cases.Comment)
+			}
+		}
+	}
+}
+
+func TestSenderCreateOutputsFromSharesMissingShare(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	testCase := caseData[0].Sending[0]
+
+	var vins []*Vin
+	for _, vin := range testCase.Given.Vin {
+		txid, _ := hex.DecodeString(vin.Txid)
+		secKey, _ := hex.DecodeString(vin.PrivateKey)
+		secretKey := utils.ConvertToFixedLength32(secKey)
+		vins = append(vins, &Vin{
+			Txid:      utils.ConvertToFixedLength32(txid),
+			Vout:      vin.Vout,
+			PublicKey: PubKeyFromSecKey(&secretKey),
+		})
+	}
+
+	recipients := []*Recipient{{SilentPaymentAddress: testCase.Given.Recipients[0]}}
+	err = SenderCreateOutputsFromShares(recipients, vins, map[[33]byte][][33]byte{}, Mainnet)
+	require.ErrorIs(t, err, ErrECDHShareMissing)
+
+	err = SenderCreateOutputsFromShares(recipients, []*Vin{{}}, nil, Mainnet)
+	require.ErrorIs(t, err, ErrNoEligibleVins)
+}
+
+// kMaxTestVins returns the vins of the first sending vector
+func kMaxTestVins(t *testing.T) []*Vin {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	var vins []*Vin
+	for _, vin := range caseData[0].Sending[0].Given.Vin {
+		txid, _ := hex.DecodeString(vin.Txid)
+		secKey, _ := hex.DecodeString(vin.PrivateKey)
+		secretKey := utils.ConvertToFixedLength32(secKey)
+		vins = append(vins, &Vin{
+			Txid:      utils.ConvertToFixedLength32(txid),
+			Vout:      vin.Vout,
+			SecretKey: &secretKey,
+		})
+	}
+	return vins
+}
+
+// kMaxTestRecipients returns n recipients paying the same address
+func kMaxTestRecipients(address string, n int) []*Recipient {
+	recipients := make([]*Recipient, n)
+	for i := range recipients {
+		recipients[i] = &Recipient{SilentPaymentAddress: address}
+	}
+	return recipients
+}
+
+func TestSenderCreateOutputsKMax(t *testing.T) {
+	vins := kMaxTestVins(t)
+
+	err := SenderCreateOutputs(kMaxTestRecipients(testAddress, int(kMax)+1), vins, Mainnet, false)
+	require.ErrorIs(t, err, ErrTooManyGroupOutputs)
+
+	recipients := kMaxTestRecipients(testAddress, int(kMax))
+	require.NoError(t, SenderCreateOutputs(recipients, vins, Mainnet, false))
+	require.Equal(t, kMax-1, recipients[kMax-1].K)
+
+	// the limit is per group, other recipients don't count towards it
+	otherSecKey := sha256.Sum256([]byte("other"))
+	otherAddress, err := CreateAddress(PubKeyFromSecKey(&otherSecKey), PubKeyFromSecKey(&otherSecKey), Mainnet, 0)
+	require.NoError(t, err)
+
+	recipients = append(kMaxTestRecipients(testAddress, int(kMax)), kMaxTestRecipients(otherAddress, 2)...)
+	require.NoError(t, SenderCreateOutputs(recipients, vins, Mainnet, false))
+	require.Equal(t, uint32(1), recipients[kMax+1].K)
+
+	recipients = append(recipients, &Recipient{SilentPaymentAddress: testAddress})
+	err = SenderCreateOutputs(recipients, vins, Mainnet, false)
+	require.ErrorIs(t, err, ErrTooManyGroupOutputs)
+
+	shares := map[[33]byte][][33]byte{}
+	err = SenderCreateOutputsFromShares(recipients, []*Vin{{PublicKey: PubKeyFromSecKey(&otherSecKey)}}, shares, Mainnet)
+	require.ErrorIs(t, err, ErrTooManyGroupOutputs)
+}
@@ -0,0 +1,137 @@
+package bip352
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// SenderOutput is an output created for a recipient by SenderCreateOutputs
+type SenderOutput struct {
+	Recipient *Recipient
+	K         uint32 // index of the output within the group of the recipient's scan key
+	PkScript  []byte // P2TR script paying to Recipient.Output
+	Amount    uint64 // value in satoshi
+}
+
+// OutputOrder determines the order of the outputs of a transaction.
+// Receivers scan all outputs of a transaction, reordering does not affect the k of an output.
+type OutputOrder uint8
+
+const (
+	// OutputOrderRecipients keeps the order of the recipients
+	OutputOrderRecipients OutputOrder = iota
+	// OutputOrderBIP69 sorts the outputs by amount and then by script as specified in BIP69
+	OutputOrderBIP69
+	// OutputOrderRandom shuffles the outputs, k can not be inferred from the position of an output
+	OutputOrderRandom
+)
+
+// NewSenderOutputs returns the outputs of recipients in the order of the recipients.
+// The outputs must have been created with one of the SenderCreateOutputs functions.
+func NewSenderOutputs(recipients []*Recipient) ([]*SenderOutput, error) {
+	outputs := make([]*SenderOutput, len(recipients))
+	for i, recipient := range recipients {
+		if recipient.Output == Zero32 {
+			return nil, ErrOutputMissing
+		}
+		if recipient.Amount > btcutil.MaxSatoshi {
+			return nil, ErrInvalidAmount
+		}
+
+		outputs[i] = &SenderOutput{
+			Recipient: recipient,
+			K:         recipient.K,
+			PkScript:  P2TRScript(recipient.Output),
+			Amount:    recipient.Amount,
+		}
+	}
+	return outputs, nil
+}
+
+// TxOut returns the output as wire.TxOut
+func (o *SenderOutput) TxOut() *wire.TxOut {
+	return wire.NewTxOut(int64(o.Amount), o.PkScript)
+}
+
+// TxOuts returns the recipients' outputs as wire.TxOut values ordered according to order.
+// The returned SenderOutputs are in the same order as the wire.TxOut values.
+func TxOuts(recipients []*Recipient, order OutputOrder) ([]*wire.TxOut, []*SenderOutput, error) {
+	outputs, err := NewSenderOutputs(recipients)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = SortSenderOutputs(outputs, order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txOuts := make([]*wire.TxOut, len(outputs))
+	for i, output := range outputs {
+		txOuts[i] = output.TxOut()
+	}
+
+	return txOuts, outputs, nil
+}
+
+// SortSenderOutputs orders outputs in place
+func SortSenderOutputs(outputs []*SenderOutput, order OutputOrder) error {
+	return orderOutputs(
+		len(outputs),
+		order,
+		func(i int) (uint64, []byte) { return outputs[i].Amount, outputs[i].PkScript },
+		func(i, j int) { outputs[i], outputs[j] = outputs[j], outputs[i] },
+	)
+}
+
+// orderOutputs orders n outputs in place.
+// output returns the amount and script of the output at i, swap swaps the outputs at i and j.
+func orderOutputs(
+	n int,
+	order OutputOrder,
+	output func(i int) (uint64, []byte),
+	swap func(i, j int),
+) error {
+	switch order {
+	case OutputOrderRecipients:
+		return nil
+	case OutputOrderBIP69:
+		sort.Stable(outputSorter{n: n, output: output, swap: swap})
+		return nil
+	case OutputOrderRandom:
+		// Fisher-Yates shuffle with randomness from crypto/rand
+		for i := n - 1; i > 0; i-- {
+			j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+			if err != nil {
+				return err
+			}
+			swap(i, int(j.Int64()))
+		}
+		return nil
+	default:
+		return ErrUnknownOutputOrder
+	}
+}
+
+// outputSorter sorts outputs by amount and then by script as specified in BIP69
+type outputSorter struct {
+	n      int
+	output func(i int) (uint64, []byte)
+	swap   func(i, j int)
+}
+
+func (s outputSorter) Len() int      { return s.n }
+func (s outputSorter) Swap(i, j int) { s.swap(i, j) }
+func (s outputSorter) Less(i, j int) bool {
+	amountI, pkScriptI := s.output(i)
+	amountJ, pkScriptJ := s.output(j)
+	if amountI != amountJ {
+		return amountI < amountJ
+	}
+	return bytes.Compare(pkScriptI, pkScriptJ) < 0
+}
@@ -0,0 +1,119 @@
+package bip352
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/stretchr/testify/require"
+)
+
+// senderOutputTestRecipients creates the outputs for two recipients with two outputs each, interleaved
+func senderOutputTestRecipients(t *testing.T) (recipients []*Recipient, scanSecKey, spendSecKey [32]byte) {
+	scanSecKey, spendSecKey = testKeys()
+	address, err := CreateAddress(PubKeyFromSecKey(&scanSecKey), PubKeyFromSecKey(&spendSecKey), Mainnet, 0)
+	require.NoError(t, err)
+
+	recipients = []*Recipient{
+		{SilentPaymentAddress: address, Amount: 3000},
+		{SilentPaymentAddress: testAddress, Amount: 1000},
+		{SilentPaymentAddress: address, Amount: 1000},
+		{SilentPaymentAddress: testAddress, Amount: 2000},
+	}
+	require.NoError(t, SenderCreateOutputs(recipients, kMaxTestVins(t), Mainnet, false))
+
+	return recipients, scanSecKey, spendSecKey
+}
+
+func TestNewSenderOutputs(t *testing.T) {
+	recipients, _, _ := senderOutputTestRecipients(t)
+
+	outputs, err := NewSenderOutputs(recipients)
+	require.NoError(t, err)
+	require.Len(t, outputs, len(recipients))
+
+	// k follows the order of the recipients within a group
+	for i, expectedK := range []uint32{0, 0, 1, 1} {
+		require.Same(t, recipients[i], outputs[i].Recipient)
+		require.Equal(t, expectedK, outputs[i].K)
+		require.Equal(t, recipients[i].Amount, outputs[i].Amount)
+		require.True(t, IsP2TR(outputs[i].PkScript))
+		require.Equal(t, recipients[i].Output[:], outputs[i].PkScript[2:])
+
+		txOut := outputs[i].TxOut()
+		require.Equal(t, int64(recipients[i].Amount), txOut.Value)
+		require.Equal(t, outputs[i].PkScript, txOut.PkScript)
+	}
+
+	// creating the outputs again gives the same result
+	recipientsAgain, _, _ := senderOutputTestRecipients(t)
+	for i := range recipients {
+		require.Equal(t, recipients[i].Output, recipientsAgain[i].Output)
+		require.Equal(t, recipients[i].K, recipientsAgain[i].K)
+	}
+
+	_, err = NewSenderOutputs([]*Recipient{{SilentPaymentAddress: testAddress}})
+	require.ErrorIs(t, err, ErrOutputMissing)
+
+	_, err = NewSenderOutputs([]*Recipient{{Output: recipients[0].Output, Amount: btcutil.MaxSatoshi + 1}})
+	require.ErrorIs(t, err, ErrInvalidAmount)
+}
+
+func TestTxOuts(t *testing.T) {
+	recipients, scanSecKey, spendSecKey := senderOutputTestRecipients(t)
+
+	txOuts, outputs, err := TxOuts(recipients, OutputOrderRecipients)
+	require.NoError(t, err)
+	for i, txOut := range txOuts {
+		require.Same(t, recipients[i], outputs[i].Recipient)
+		require.Equal(t, outputs[i].PkScript, txOut.PkScript)
+	}
+
+	txOuts, outputs, err = TxOuts(recipients, OutputOrderBIP69)
+	require.NoError(t, err)
+	for i := 1; i < len(txOuts); i++ {
+		require.LessOrEqual(t, txOuts[i-1].Value, txOuts[i].Value)
+		if txOuts[i-1].Value == txOuts[i].Value {
+			require.Negative(t, bytes.Compare(txOuts[i-1].PkScript, txOuts[i].PkScript))
+		}
+		require.Equal(t, outputs[i].PkScript, txOuts[i].PkScript)
+	}
+
+	_, _, err = TxOuts(recipients, OutputOrder(42))
+	require.ErrorIs(t, err, ErrUnknownOutputOrder)
+
+	// the receiver finds its outputs regardless of the order
+	vins := kMaxTestVins(t)
+	var secretKeys [][32]byte
+	for _, vin := range vins {
+		secretKeys = append(secretKeys, *vin.SecretKey)
+	}
+	secretKeySum := RecursiveAddPrivateKeys(secretKeys)
+	publicKeySum := PubKeyFromSecKey(&secretKeySum)
+	inputHash, err := ComputeInputHash(vins, publicKeySum)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		txOuts, outputs, err = TxOuts(recipients, OutputOrderRandom)
+		require.NoError(t, err)
+		require.ElementsMatch(t, recipients, []*Recipient{
+			outputs[0].Recipient, outputs[1].Recipient, outputs[2].Recipient, outputs[3].Recipient,
+		})
+
+		var txOutputs [][32]byte
+		for _, txOut := range txOuts {
+			txOutputs = append(txOutputs, [32]byte(txOut.PkScript[2:]))
+		}
+
+		foundOutputs, err := ReceiverScanTransaction(scanSecKey, PubKeyFromSecKey(&spendSecKey), nil, txOutputs, publicKeySum, inputHash)
+		require.NoError(t, err)
+		require.Len(t, foundOutputs, 2)
+		for k, foundOutput := range foundOutputs {
+			for _, output := range outputs {
+				if output.Recipient.Output == foundOutput.Output {
+					require.Equal(t, uint32(k), output.K)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package bip352
+
+import (
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// ECDHSigner multiplies public keys with a secret key without exposing the secret key.
+// This allows the secret to live in an HSM, a hardware wallet or a remote signer.
+type ECDHSigner interface {
+	// PubKey returns the 33 byte compressed public key of the secret key
+	PubKey() [33]byte
+	// ECDH returns secretKey * publicKey
+	ECDH(publicKey *[33]byte) ([33]byte, error)
+}
+
+// Signer can additionally produce BIP340 signatures
+type Signer interface {
+	ECDHSigner
+	// SignSchnorr signs hash with secretKey + tweak.
+	// tweak can be nil to sign with the secret key itself.
+	// auxRand is the BIP340 auxiliary randomness, if nil a deterministic nonce is used.
+	SignSchnorr(hash [32]byte, tweak *[32]byte, auxRand *[32]byte) ([64]byte, error)
+}
+
+// PrivateKeySigner is a Signer for a secret key held in memory
+type PrivateKeySigner struct {
+	secretKey [32]byte
+	pubKey    [33]byte
+}
+
+var _ Signer = (*PrivateKeySigner)(nil)
+
+func NewPrivateKeySigner(secretKey [32]byte) (*PrivateKeySigner, error) {
+	var scalar btcec.ModNScalar
+	if overflow := scalar.SetBytes(&secretKey); overflow != 0 || scalar.IsZero() {
+		return nil, ErrInvalidSecretKey
+	}
+
+	return &PrivateKeySigner{
+		secretKey: secretKey,
+		pubKey:    *PubKeyFromSecKey(&secretKey),
+	}, nil
+}
+
+func (s *PrivateKeySigner) PubKey() [33]byte {
+	return s.pubKey
+}
+
+func (s *PrivateKeySigner) ECDH(publicKey *[33]byte) ([33]byte, error) {
+	result, err := CreateSharedSecret(publicKey, &s.secretKey, nil)
+	if err != nil {
+		return [33]byte{}, err
+	}
+
+	return *result, nil
+}
+
+func (s *PrivateKeySigner) SignSchnorr(hash [32]byte, tweak *[32]byte, auxRand *[32]byte) ([64]byte, error) {
+	secretKey := s.secretKey
+	if tweak != nil {
+		err := AddPrivateKeys(&secretKey, tweak)
+		if err != nil {
+			return [64]byte{}, err
+		}
+	}
+
+	privKey, _ := btcec.PrivKeyFromBytes(secretKey[:])
+
+	var opts []schnorr.SignOption
+	if auxRand != nil {
+		opts = append(opts, schnorr.CustomNonce(*auxRand))
+	}
+
+	signature, err := schnorr.Sign(privKey, hash[:], opts...)
+	if err != nil {
+		return [64]byte{}, err
+	}
+
+	var sig [64]byte
+	copy(sig[:], signature.Serialize())
+	return sig, nil
+}
+
+// signingPubKey returns the public key of a vin from its secret key or signer.
+// Taproot keys are returned with an even y-coordinate.
+func signingPubKey(vin *Vin) ([33]byte, error) {
+	if vin.SecretKey != nil {
+		secretKey := *vin.SecretKey
+		if vin.Taproot {
+			secretKey = checkToNegate(secretKey)
+		}
+		return *PubKeyFromSecKey(&secretKey), nil
+	}
+
+	if vin.Signer == nil {
+		return [33]byte{}, ErrSecretKeyMissing
+	}
+
+	pubKey := vin.Signer.PubKey()
+	if vin.Taproot {
+		pubKey[0] = 0x02
+	}
+	return pubKey, nil
+}
+
+// ecdhShare computes the share a*B_scan of a vin with its secret key or signer.
+// The share is negated for taproot inputs with an odd public key.
+func ecdhShare(vin *Vin, scanPubKey [33]byte) ([33]byte, error) {
+	if vin.SecretKey != nil {
+		secretKey := *vin.SecretKey
+		if vin.Taproot {
+			secretKey = checkToNegate(secretKey)
+		}
+
+		share, err := CreateSharedSecret(&scanPubKey, &secretKey, nil)
+		if err != nil {
+			return [33]byte{}, err
+		}
+		return *share, nil
+	}
+
+	if vin.Signer == nil {
+		return [33]byte{}, ErrSecretKeyMissing
+	}
+
+	share, err := vin.Signer.ECDH(&scanPubKey)
+	if err != nil {
+		return [33]byte{}, err
+	}
+
+	// (-a)*B = -(a*B)
+	if vin.Taproot && vin.Signer.PubKey()[0] == 0x03 {
+		err = NegatePublicKey(&share)
+		if err != nil {
+			return [33]byte{}, err
+		}
+	}
+
+	return share, nil
+}
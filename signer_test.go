@@ -0,0 +1,172 @@
+package bip352
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/setavenger/blindbit-lib/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPrivateKeySigner(t *testing.T) {
+	_, err := NewPrivateKeySigner([32]byte{})
+	require.ErrorIs(t, err, ErrInvalidSecretKey)
+
+	// the group order n is not a valid secret key
+	order, _ := hex.DecodeString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
+	_, err = NewPrivateKeySigner(utils.ConvertToFixedLength32(order))
+	require.ErrorIs(t, err, ErrInvalidSecretKey)
+
+	secretKey := sha256.Sum256([]byte("secret"))
+	signer, err := NewPrivateKeySigner(secretKey)
+	require.NoError(t, err)
+	require.Equal(t, *PubKeyFromSecKey(&secretKey), signer.PubKey())
+}
+
+func TestPrivateKeySignerSignSchnorr(t *testing.T) {
+	secretKey := sha256.Sum256([]byte("secret"))
+	tweak := sha256.Sum256([]byte("tweak"))
+	hash := sha256.Sum256([]byte("message"))
+	auxRand := sha256.Sum256([]byte("random auxiliary data"))
+
+	signer, err := NewPrivateKeySigner(secretKey)
+	require.NoError(t, err)
+
+	tweakedSecretKey := secretKey
+	require.NoError(t, AddPrivateKeys(&tweakedSecretKey, &tweak))
+
+	for _, testCase := range []struct {
+		tweak     *[32]byte
+		auxRand   *[32]byte
+		secretKey [32]byte
+	}{
+		{nil, nil, secretKey},
+		{nil, &auxRand, secretKey},
+		{&tweak, &auxRand, tweakedSecretKey},
+	} {
+		sig, err := signer.SignSchnorr(hash, testCase.tweak, testCase.auxRand)
+		require.NoError(t, err)
+
+		signature, err := schnorr.ParseSignature(sig[:])
+		require.NoError(t, err)
+		pubKey, err := schnorr.ParsePubKey(PubKeyFromSecKey(&testCase.secretKey)[1:])
+		require.NoError(t, err)
+		require.True(t, signature.Verify(hash[:], pubKey))
+	}
+}
+
+func TestSenderCreateOutputsWithSigner(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	for _, cases := range caseData {
+		for _, testCase := range cases.Sending {
+			var vins []*Vin
+			for i, vin := range testCase.Given.Vin {
+				txid, err := hex.DecodeString(vin.Txid)
+				require.NoError(t, err)
+				secKey, err := hex.DecodeString(vin.PrivateKey)
+				require.NoError(t, err)
+				scriptPubKey, err := hex.DecodeString(vin.Prevout.ScriptPubKey.Hex)
+				require.NoError(t, err)
+				scriptSig, err := hex.DecodeString(vin.ScriptSig)
+				require.NoError(t, err)
+				witness, _ := hex.DecodeString(vin.Txinwitness)
+				var witnessScript [][]byte
+				if len(witness) > 0 {
+					witnessScript, err = ParseWitnessScript(witness)
+					require.NoError(t, err)
+				}
+
+				vinInner := &Vin{
+					Txid:         utils.ConvertToFixedLength32(txid),
+					Vout:         vin.Vout,
+					ScriptPubKey: scriptPubKey,
+					ScriptSig:    scriptSig,
+					Witness:      witnessScript,
+					Taproot:      IsP2TR(scriptPubKey),
+				}
+
+				// mix inputs with secret keys and inputs with signers
+				secretKey := utils.ConvertToFixedLength32(secKey)
+				if i%2 == 0 {
+					vinInner.Signer, err = NewPrivateKeySigner(secretKey)
+					require.NoError(t, err)
+				} else {
+					vinInner.SecretKey = &secretKey
+				}
+				vins = append(vins, vinInner)
+			}
+
+			var recipients []*Recipient
+			for _, address := range testCase.Given.Recipients {
+				recipients = append(recipients, &Recipient{SilentPaymentAddress: address})
+			}
+
+			err = SenderCreateOutputs(recipients, vins, Mainnet, true)
+			if errors.Is(err, ErrNoEligibleVins) {
+				require.Empty(t, testCase.Expected.Outputs, cases.Comment)
+				continue
+			}
+			require.NoError(t, err, cases.Comment)
+
+			for _, recipient := range recipients {
+				require.Contains(t, testCase.Expected.Outputs, hex.EncodeToString(recipient.Output[:]), cases.Comment)
+			}
+		}
+	}
+}
+
+func TestSenderCreateOutputsSecretKeyMissing(t *testing.T) {
+	vins := kMaxTestVins(t)
+	vins[1].SecretKey = nil
+
+	recipients := []*Recipient{{SilentPaymentAddress: testAddress}}
+	err := SenderCreateOutputs(recipients, vins, Mainnet, false)
+	require.ErrorIs(t, err, ErrSecretKeyMissing)
+	require.Equal(t, Zero32, recipients[0].Output)
+}
+
+func TestReceiverScanTransactionWithSigner(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	for _, cases := range caseData {
+		for _, testCase := range cases.Receiving {
+			secKeyScan, secKeySpend := testVectorKeys(t, testCase.Given.KeyMaterial.ScanPrivKey, testCase.Given.KeyMaterial.SpendPrivKey)
+			spendPubKey := PubKeyFromSecKey(&secKeySpend)
+
+			scanSigner, err := NewPrivateKeySigner(secKeyScan)
+			require.NoError(t, err)
+
+			var labels []*Label
+			for _, m := range testCase.Given.Labels {
+				label, err := CreateLabel(&secKeyScan, m)
+				require.NoError(t, err)
+				labels = append(labels, &label)
+			}
+
+			tx, fetcher := txFromTestVins(t, testCase.Given.Vin, testCase.Given.Outputs)
+			txData, err := ExtractTxData(tx, fetcher)
+			if errors.Is(err, ErrNoEligibleVins) {
+				require.Empty(t, testCase.Expected.Outputs, cases.Comment)
+				continue
+			}
+			require.NoError(t, err, cases.Comment)
+
+			foundOutputs, err := ReceiverScanTransactionWithSigner(
+				scanSigner, spendPubKey, labels, txData.Outputs, txData.PublicKeySum, txData.InputHash,
+			)
+			require.NoError(t, err, cases.Comment)
+			require.Len(t, foundOutputs, len(testCase.Expected.Outputs), cases.Comment)
+
+			for i, foundOutput := range foundOutputs {
+				require.Equal(t, testCase.Expected.Outputs[i].PubKey, hex.EncodeToString(foundOutput.Output[:]), cases.Comment)
+				require.Equal(t, testCase.Expected.Outputs[i].PrivKeyTweak, hex.EncodeToString(foundOutput.SecKeyTweak[:]), cases.Comment)
+			}
+		}
+	}
+}
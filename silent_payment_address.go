@@ -0,0 +1,186 @@
+package bip352
+
+import (
+	"errors"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// SilentPaymentAddress is a decoded and validated silent payment address.
+// The zero value is not a valid address, use one of the constructors or ParseAddress.
+type SilentPaymentAddress struct {
+	scanPubKey  [33]byte
+	spendPubKey [33]byte
+	network     Network
+	version     uint8
+}
+
+// NewSilentPaymentAddress creates an address from the scan and spend public key.
+// Both keys are checked to be valid points on the curve and the version has to be readable by a v0 implementation.
+func NewSilentPaymentAddress(
+	scanPubKey, spendPubKey [33]byte,
+	network Network,
+	version uint8,
+) (SilentPaymentAddress, error) {
+	if err := checkAddressVersion(version); err != nil {
+		return SilentPaymentAddress{}, err
+	}
+	if err := validatePublicKey(scanPubKey); err != nil {
+		return SilentPaymentAddress{}, err
+	}
+	if err := validatePublicKey(spendPubKey); err != nil {
+		return SilentPaymentAddress{}, err
+	}
+
+	return SilentPaymentAddress{
+		scanPubKey:  scanPubKey,
+		spendPubKey: spendPubKey,
+		network:     network,
+		version:     version,
+	}, nil
+}
+
+// NewLabeledSilentPaymentAddress creates the address for a label.
+// The spend key of the returned address is B_m = B_spend + label
+func NewLabeledSilentPaymentAddress(
+	scanPubKey, spendPubKey [33]byte,
+	network Network,
+	version uint8,
+	label *Label,
+) (SilentPaymentAddress, error) {
+	if label == nil {
+		return SilentPaymentAddress{}, ErrLabelMissing
+	}
+
+	labeledSpendPubKey, err := CreateLabelledSpendPubKey(&spendPubKey, &label.PubKey)
+	if err != nil {
+		return SilentPaymentAddress{}, err
+	}
+
+	return NewSilentPaymentAddress(scanPubKey, labeledSpendPubKey, network, version)
+}
+
+// ParseAddress decodes and validates a silent payment address.
+// The network is derived from the hrp of the address.
+// Testnet, testnet4 and signet share an hrp, ErrNetworkAmbiguous is returned for their addresses, use ParseAddressForNetwork.
+func ParseAddress(address string) (SilentPaymentAddress, error) {
+	hrp, data, version, err := decodeAddress(address)
+	if err != nil {
+		return SilentPaymentAddress{}, err
+	}
+
+	network, err := NetworkFromHRP(hrp)
+	if err != nil {
+		return SilentPaymentAddress{}, err
+	}
+
+	return addressFromData(data, network, version)
+}
+
+// ParseAddressForNetwork decodes and validates a silent payment address of network.
+// Returns AddressHRPError if the address belongs to another network.
+func ParseAddressForNetwork(address string, network Network) (SilentPaymentAddress, error) {
+	hrp, data, version, err := decodeAddress(address)
+	if err != nil {
+		return SilentPaymentAddress{}, err
+	}
+
+	if hrp != network.HRP() {
+		return SilentPaymentAddress{}, AddressHRPError
+	}
+
+	return addressFromData(data, network, version)
+}
+
+// addressFromData creates the address from the decoded address payload
+func addressFromData(data []byte, network Network, version uint8) (SilentPaymentAddress, error) {
+	scanPubKey, spendPubKey, err := splitAddressData(data)
+	if err != nil {
+		return SilentPaymentAddress{}, err
+	}
+
+	return NewSilentPaymentAddress(scanPubKey, spendPubKey, network, version)
+}
+
+// String returns the bech32m encoding of the address or an empty string if it can not be encoded, see MarshalText
+func (a SilentPaymentAddress) String() string {
+	address, err := a.encode()
+	if err != nil {
+		return ""
+	}
+	return address
+}
+
+// encode validates the keys and the version and returns the bech32m encoding of the address
+func (a SilentPaymentAddress) encode() (string, error) {
+	if err := validatePublicKey(a.scanPubKey); err != nil {
+		return "", err
+	}
+	if err := validatePublicKey(a.spendPubKey); err != nil {
+		return "", err
+	}
+	return CreateAddress(&a.scanPubKey, &a.spendPubKey, a.network, a.version)
+}
+
+// ScanKey returns the 33 byte compressed scan public key B_scan
+func (a SilentPaymentAddress) ScanKey() [33]byte {
+	return a.scanPubKey
+}
+
+// SpendKey returns the 33 byte compressed spend public key, B_spend or B_m for labeled addresses
+func (a SilentPaymentAddress) SpendKey() [33]byte {
+	return a.spendPubKey
+}
+
+func (a SilentPaymentAddress) Version() uint8 {
+	return a.version
+}
+
+func (a SilentPaymentAddress) Network() Network {
+	return a.network
+}
+
+// MarshalText implements encoding.TextMarshaler, it is also used for JSON
+func (a SilentPaymentAddress) MarshalText() ([]byte, error) {
+	address, err := a.encode()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(address), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, it is also used for JSON.
+// The network is derived from the hrp as in ParseAddress.
+// The text does not tell testnet, testnet4 and signet apart, for their "tsp" hrp the network of a is kept
+// if it is one of them and Testnet is used otherwise.
+func (a *SilentPaymentAddress) UnmarshalText(text []byte) error {
+	hrp, data, version, err := decodeAddress(string(text))
+	if err != nil {
+		return err
+	}
+
+	network := a.network
+	if network.HRP() != hrp {
+		network, err = NetworkFromHRP(hrp)
+		if errors.Is(err, ErrNetworkAmbiguous) {
+			network, err = Testnet, nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	address, err := addressFromData(data, network, version)
+	if err != nil {
+		return err
+	}
+	*a = address
+	return nil
+}
+
+func validatePublicKey(pubKey [33]byte) error {
+	if _, err := btcec.ParsePubKey(pubKey[:]); err != nil {
+		return ErrInvalidPublicKey
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package bip352
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/setavenger/blindbit-lib/utils"
+	"github.com/stretchr/testify/require"
+)
+
+const testAddress = "sp1qqgste7k9hx0qftg6qmwlkqtwuy6cycyavzmzj85c6qdfhjdpdjtdgqjuexzk6murw56suy3e0rd2cgqvycxttddwsvgxe2usfpxumr70xc9pkqwv"
+
+// encodeTestAddress encodes an arbitrary payload with the given version, no validation is done
+func encodeTestAddress(t *testing.T, hrp string, version uint8, payload []byte) string {
+	converted, err := bech32.ConvertBits(payload, 8, 5, true)
+	require.NoError(t, err)
+	address, err := bech32.EncodeM(hrp, append([]byte{version}, converted...))
+	require.NoError(t, err)
+	return address
+}
+
+func TestParseAddress(t *testing.T) {
+	scanPubKey, _ := hex.DecodeString("0220bcfac5b99e04ad1a06ddfb016ee13582609d60b6291e98d01a9bc9a16c96d4")
+	spendPubKey, _ := hex.DecodeString("025cc9856d6f8375350e123978daac200c260cb5b5ae83106cab90484dcd8fcf36")
+
+	address, err := ParseAddress(testAddress)
+	require.NoError(t, err)
+
+	require.Equal(t, utils.ConvertToFixedLength33(scanPubKey), address.ScanKey())
+	require.Equal(t, utils.ConvertToFixedLength33(spendPubKey), address.SpendKey())
+	require.Equal(t, Mainnet, address.Network())
+	require.Equal(t, uint8(0), address.Version())
+	require.Equal(t, testAddress, address.String())
+
+	// the payload has to contain both keys
+	shortAddress := encodeTestAddress(t, "sp", 0, scanPubKey)
+	_, err = ParseAddress(shortAddress)
+	require.ErrorIs(t, err, ErrInvalidAddressLength)
+	_, _, err = DecodeSilentPaymentAddressToKeys(shortAddress, Mainnet)
+	require.ErrorIs(t, err, ErrInvalidAddressLength)
+
+	// keys have to be on the curve
+	invalidPoint := make([]byte, 33)
+	invalidPoint[0] = 0x02
+	_, err = ParseAddress(encodeTestAddress(t, "sp", 0, append(scanPubKey, invalidPoint...)))
+	require.ErrorIs(t, err, ErrInvalidPublicKey)
+
+	_, err = ParseAddress(encodeTestAddress(t, "bc", 0, append(scanPubKey, spendPubKey...)))
+	require.ErrorIs(t, err, AddressHRPError)
+}
+
+func TestSilentPaymentAddressLabeled(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	// case with labels
+	testCase := caseData[12].Receiving[0]
+	require.NotEmpty(t, testCase.Given.Labels)
+
+	scanSecKey, spendSecKey := testVectorKeys(t, testCase.Given.KeyMaterial.ScanPrivKey, testCase.Given.KeyMaterial.SpendPrivKey)
+	scanPubKey := PubKeyFromSecKey(&scanSecKey)
+	spendPubKey := PubKeyFromSecKey(&spendSecKey)
+
+	for _, m := range testCase.Given.Labels {
+		label, err := CreateLabel(&scanSecKey, m)
+		require.NoError(t, err)
+
+		address, err := NewLabeledSilentPaymentAddress(*scanPubKey, *spendPubKey, Mainnet, 0, &label)
+		require.NoError(t, err)
+
+		expected, err := CreateLabeledAddress(scanPubKey, spendPubKey, Mainnet, 0, &scanSecKey, m)
+		require.NoError(t, err)
+		require.Equal(t, expected, address.String())
+		require.Contains(t, testCase.Expected.Addresses, address.String())
+	}
+
+	_, err = NewLabeledSilentPaymentAddress(*scanPubKey, *spendPubKey, Mainnet, 0, nil)
+	require.ErrorIs(t, err, ErrLabelMissing)
+}
+
+func TestNewSilentPaymentAddressVersion(t *testing.T) {
+	address, err := ParseAddress(testAddress)
+	require.NoError(t, err)
+
+	future, err := NewSilentPaymentAddress(address.ScanKey(), address.SpendKey(), Mainnet, 30)
+	require.NoError(t, err)
+	require.NotEmpty(t, future.String())
+
+	// versions which can not be encoded are rejected instead of producing empty addresses
+	_, err = NewSilentPaymentAddress(address.ScanKey(), address.SpendKey(), Mainnet, 31)
+	require.ErrorIs(t, err, ErrAddressVersionIncompatible)
+	_, err = NewSilentPaymentAddress(address.ScanKey(), address.SpendKey(), Mainnet, 32)
+	require.ErrorIs(t, err, ErrAddressVersionInvalid)
+}
+
+func TestSilentPaymentAddressJSON(t *testing.T) {
+	address, err := ParseAddress(testAddress)
+	require.NoError(t, err)
+
+	type wrapper struct {
+		Address SilentPaymentAddress `json:"address"`
+	}
+
+	data, err := json.Marshal(wrapper{Address: address})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"address":"`+testAddress+`"}`, string(data))
+
+	var decoded wrapper
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, address, decoded.Address)
+
+	require.Error(t, json.Unmarshal([]byte(`{"address":"sp1invalid"}`), &decoded))
+
+	// the zero value can not be marshaled
+	_, err = json.Marshal(wrapper{})
+	require.ErrorIs(t, err, ErrInvalidPublicKey)
+
+	// both keys are validated
+	invalidSpendKey := address
+	invalidSpendKey.spendPubKey = [33]byte{0x02}
+	_, err = invalidSpendKey.MarshalText()
+	require.ErrorIs(t, err, ErrInvalidPublicKey)
+	require.Empty(t, invalidSpendKey.String())
+
+	// encoding errors are returned
+	invalidVersion := address
+	invalidVersion.version = 31
+	_, err = invalidVersion.MarshalText()
+	require.ErrorIs(t, err, ErrAddressVersionIncompatible)
+}
+
+func TestSilentPaymentAddressJSONSignet(t *testing.T) {
+	scanSecKey, spendSecKey := testKeys()
+	address, err := NewSilentPaymentAddress(*PubKeyFromSecKey(&scanSecKey), *PubKeyFromSecKey(&spendSecKey), Signet, 0)
+	require.NoError(t, err)
+
+	type wrapper struct {
+		Address SilentPaymentAddress `json:"address"`
+	}
+
+	data, err := json.Marshal(wrapper{Address: address})
+	require.NoError(t, err)
+
+	// the network of the target is kept for the shared hrp
+	decoded := wrapper{Address: address}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, address, decoded.Address)
+
+	// without a test network in the target testnet is used
+	decoded = wrapper{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, Testnet, decoded.Address.Network())
+	require.Equal(t, address.String(), decoded.Address.String())
+	require.Equal(t, address.ScanKey(), decoded.Address.ScanKey())
+	require.Equal(t, address.SpendKey(), decoded.Address.SpendKey())
+
+	// a regtest address replaces the network of the target
+	regtest, err := NewSilentPaymentAddress(address.ScanKey(), address.SpendKey(), Regtest, 0)
+	require.NoError(t, err)
+	data, err = json.Marshal(wrapper{Address: regtest})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, regtest, decoded.Address)
+}
@@ -0,0 +1,54 @@
+package bip352
+
+import (
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// PrivateKey returns the secret key to spend the output: b_spend + t_k (+ label tweak).
+// The key is negated if necessary so that it belongs to the even public key of the x-only output.
+// Returns ErrOutputKeyMismatch if spendSecKey does not belong to the output.
+func (f *FoundOutput) PrivateKey(spendSecKey [32]byte) ([32]byte, error) {
+	secretKey := spendSecKey
+	tweak := f.SecKeyTweak
+	err := AddPrivateKeys(&secretKey, &tweak)
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	pubKey := PubKeyFromSecKey(&secretKey)
+	if [32]byte(pubKey[1:]) != f.Output {
+		return [32]byte{}, ErrOutputKeyMismatch
+	}
+
+	if pubKey[0] == 0x03 {
+		secretKey = NegateSecretKey(secretKey)
+	}
+
+	return secretKey, nil
+}
+
+// SignKeyPath creates a BIP340 signature over sighash for a taproot key-path spend of the output.
+// signer has to hold b_spend, the output tweak is applied by the signer.
+// auxRand is the BIP340 auxiliary randomness and should be fresh randomness for every signature.
+// The signature is verified against the output before it is returned.
+func (f *FoundOutput) SignKeyPath(signer Signer, sighash [32]byte, auxRand *[32]byte) ([64]byte, error) {
+	tweak := f.SecKeyTweak
+	sig, err := signer.SignSchnorr(sighash, &tweak, auxRand)
+	if err != nil {
+		return [64]byte{}, err
+	}
+
+	outputPubKey, err := schnorr.ParsePubKey(f.Output[:])
+	if err != nil {
+		return [64]byte{}, err
+	}
+	signature, err := schnorr.ParseSignature(sig[:])
+	if err != nil {
+		return [64]byte{}, err
+	}
+	if !signature.Verify(sighash[:], outputPubKey) {
+		return [64]byte{}, ErrOutputKeyMismatch
+	}
+
+	return sig, nil
+}
@@ -0,0 +1,100 @@
+package bip352
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/setavenger/blindbit-lib/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFoundOutputSignKeyPath(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	msgHash := sha256.Sum256([]byte("message"))
+	auxHash := sha256.Sum256([]byte("random auxiliary data"))
+
+	for _, cases := range caseData {
+		for _, testCase := range cases.Receiving {
+			secKeyScan, secKeySpend := testVectorKeys(t, testCase.Given.KeyMaterial.ScanPrivKey, testCase.Given.KeyMaterial.SpendPrivKey)
+			spendPubKey := PubKeyFromSecKey(&secKeySpend)
+
+			spendSigner, err := NewPrivateKeySigner(secKeySpend)
+			require.NoError(t, err)
+
+			var labels []*Label
+			for _, m := range testCase.Given.Labels {
+				label, err := CreateLabel(&secKeyScan, m)
+				require.NoError(t, err)
+				labels = append(labels, &label)
+			}
+
+			tx, fetcher := txFromTestVins(t, testCase.Given.Vin, testCase.Given.Outputs)
+			foundOutputs, err := ReceiverScanTx(secKeyScan, spendPubKey, labels, tx, fetcher)
+			if errors.Is(err, ErrNoEligibleVins) {
+				continue
+			}
+			require.NoError(t, err, cases.Comment)
+			require.Len(t, foundOutputs, len(testCase.Expected.Outputs), cases.Comment)
+
+			for i, foundOutput := range foundOutputs {
+				sig, err := foundOutput.SignKeyPath(spendSigner, msgHash, &auxHash)
+				require.NoError(t, err, cases.Comment)
+				require.Equal(t, testCase.Expected.Outputs[i].Signature, hex.EncodeToString(sig[:]), cases.Comment)
+
+				// the private key signs for the even output key
+				privateKey, err := foundOutput.PrivateKey(secKeySpend)
+				require.NoError(t, err, cases.Comment)
+				pubKey := PubKeyFromSecKey(&privateKey)
+				require.Equal(t, byte(0x02), pubKey[0], cases.Comment)
+				require.Equal(t, foundOutput.Output[:], pubKey[1:], cases.Comment)
+			}
+		}
+	}
+}
+
+func TestFoundOutputWrongSpendKey(t *testing.T) {
+	_, spendSecKey := testKeys()
+	tweak := sha256.Sum256([]byte("tweak"))
+
+	outputSecKey := spendSecKey
+	require.NoError(t, AddPrivateKeys(&outputSecKey, &tweak))
+	foundOutput := &FoundOutput{
+		Output:      utils.ConvertToFixedLength32(PubKeyFromSecKey(&outputSecKey)[1:]),
+		SecKeyTweak: tweak,
+	}
+
+	privateKey, err := foundOutput.PrivateKey(spendSecKey)
+	require.NoError(t, err)
+
+	hash := sha256.Sum256([]byte("message"))
+	signer, err := NewPrivateKeySigner(spendSecKey)
+	require.NoError(t, err)
+	sig, err := foundOutput.SignKeyPath(signer, hash, nil)
+	require.NoError(t, err)
+
+	// the key from PrivateKey can be used directly without tweak
+	privateKeySigner, err := NewPrivateKeySigner(privateKey)
+	require.NoError(t, err)
+	sigPrivateKey, err := privateKeySigner.SignSchnorr(hash, nil, nil)
+	require.NoError(t, err)
+	signature, err := schnorr.ParseSignature(sigPrivateKey[:])
+	require.NoError(t, err)
+	outputPubKey, err := schnorr.ParsePubKey(foundOutput.Output[:])
+	require.NoError(t, err)
+	require.True(t, signature.Verify(hash[:], outputPubKey))
+	require.Equal(t, sig, sigPrivateKey)
+
+	wrongSecKey := sha256.Sum256([]byte("wrong"))
+	_, err = foundOutput.PrivateKey(wrongSecKey)
+	require.ErrorIs(t, err, ErrOutputKeyMismatch)
+
+	wrongSigner, err := NewPrivateKeySigner(wrongSecKey)
+	require.NoError(t, err)
+	_, err = foundOutput.SignKeyPath(wrongSigner, hash, nil)
+	require.ErrorIs(t, err, ErrOutputKeyMismatch)
+}
@@ -0,0 +1,25 @@
+package bip352
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/setavenger/blindbit-lib/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// testKeys returns the scan and spend secret keys of the receiver used throughout the tests
+func testKeys() (scanSecKey, spendSecKey [32]byte) {
+	return sha256.Sum256([]byte("scan")), sha256.Sum256([]byte("spend"))
+}
+
+// testVectorKeys decodes the key material of a receiving test vector
+func testVectorKeys(tb testing.TB, scanPrivKey, spendPrivKey string) (scanSecKey, spendSecKey [32]byte) {
+	scanSecKeyBytes, err := hex.DecodeString(scanPrivKey)
+	require.NoError(tb, err)
+	spendSecKeyBytes, err := hex.DecodeString(spendPrivKey)
+	require.NoError(tb, err)
+
+	return utils.ConvertToFixedLength32(scanSecKeyBytes), utils.ConvertToFixedLength32(spendSecKeyBytes)
+}
@@ -0,0 +1,226 @@
+package bip352
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/setavenger/blindbit-lib/utils"
+)
+
+// TxData holds everything that is needed from a transaction in order to send to or scan for silent payments
+type TxData struct {
+	Vins         []*Vin     // all inputs of the transaction in order, populated with prevout data
+	Outputs      [][32]byte // x-only keys of all taproot outputs in order
+	PublicKeySum *[33]byte  // A_sum: sum of the public keys of all eligible inputs
+	InputHash    *[32]byte  // input_hash computed over all inputs and A_sum
+}
+
+// VinsFromTx converts the inputs of a transaction into Vins.
+// The prevouts are fetched from fetcher, every input must have a corresponding prevout.
+// Secret keys are not set, the Vins can be used for scanning or have to be amended before sending.
+func VinsFromTx(tx *wire.MsgTx, fetcher txscript.PrevOutputFetcher) ([]*Vin, error) {
+	vins := make([]*Vin, len(tx.TxIn))
+	for i, txIn := range tx.TxIn {
+		prevOut := fetcher.FetchPrevOutput(txIn.PreviousOutPoint)
+		if prevOut == nil {
+			return nil, fmt.Errorf("%w: %s", ErrPrevOutMissing, txIn.PreviousOutPoint)
+		}
+
+		// chainhash stores the txid in internal byte order, Vin expects the human-readable format
+		txid := utils.ConvertToFixedLength32(ReverseBytesCopy(txIn.PreviousOutPoint.Hash[:]))
+
+		vins[i] = &Vin{
+			Txid:         txid,
+			Vout:         txIn.PreviousOutPoint.Index,
+			Amount:       uint64(prevOut.Value),
+			Taproot:      IsP2TR(prevOut.PkScript),
+			Witness:      txIn.Witness,
+			ScriptPubKey: prevOut.PkScript,
+			ScriptSig:    txIn.SignatureScript,
+		}
+	}
+
+	return vins, nil
+}
+
+// P2TRScript returns the pay-to-taproot script of an x-only output key
+func P2TRScript(output [32]byte) []byte {
+	return append([]byte{txscript.OP_1, txscript.OP_DATA_32}, output[:]...)
+}
+
+// TaprootOutputs returns the x-only keys of all taproot outputs of a transaction
+func TaprootOutputs(tx *wire.MsgTx) [][32]byte {
+	var outputs [][32]byte
+	for _, txOut := range tx.TxOut {
+		if IsP2TR(txOut.PkScript) {
+			outputs = append(outputs, utils.ConvertToFixedLength32(txOut.PkScript[2:]))
+		}
+	}
+	return outputs
+}
+
+// SumInputPublicKeys extracts the public keys of all eligible vins and returns A_sum.
+// Taproot keys are treated as having an even y-coordinate.
+// Returns ErrNoEligibleVins if not a single public key could be extracted
+// and ErrSegwitVersionUnsupported if any vin spends a segwit version above 1.
+func SumInputPublicKeys(vins []*Vin) (*[33]byte, error) {
+	if spendsSegwitVersionAboveOne(vins) {
+		return nil, ErrSegwitVersionUnsupported
+	}
+
+	var pubKeys [][33]byte
+	for _, vin := range vins {
+		pubKey, utxoType := ExtractPubKey(vin)
+		if utxoType == Unknown || pubKey == nil {
+			continue
+		}
+
+		if utxoType == P2TR {
+			pubKey = append([]byte{0x02}, pubKey...)
+		}
+
+		pubKeys = append(pubKeys, utils.ConvertToFixedLength33(pubKey))
+	}
+
+	if len(pubKeys) == 0 {
+		return nil, ErrNoEligibleVins
+	}
+
+	return SumPublicKeys(pubKeys)
+}
+
+// ExtractTxData parses a signed transaction and computes A_sum and the input_hash.
+// Returns ErrNoEligibleVins if none of the inputs can be used for the shared secret derivation
+// and ErrSegwitVersionUnsupported if the transaction must not be scanned.
+func ExtractTxData(tx *wire.MsgTx, fetcher txscript.PrevOutputFetcher) (*TxData, error) {
+	vins, err := VinsFromTx(tx, fetcher)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeySum, err := SumInputPublicKeys(vins)
+	if err != nil {
+		return nil, err
+	}
+
+	inputHash, err := ComputeInputHash(vins, publicKeySum)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TxData{
+		Vins:         vins,
+		Outputs:      TaprootOutputs(tx),
+		PublicKeySum: publicKeySum,
+		InputHash:    inputHash,
+	}, nil
+}
+
+// ReceiverScanTx scans a transaction for outputs belonging to the receiver.
+// See ReceiverScanTransaction for the key arguments.
+func ReceiverScanTx(
+	scanKey [32]byte,
+	receiverSpendPubKey *[33]byte,
+	labels []*Label,
+	tx *wire.MsgTx,
+	fetcher txscript.PrevOutputFetcher,
+) ([]*FoundOutput, error) {
+	txData, err := ExtractTxData(tx, fetcher)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(txData.Outputs) == 0 {
+		return nil, nil
+	}
+
+	return ReceiverScanTransaction(
+		scanKey, receiverSpendPubKey, labels, txData.Outputs, txData.PublicKeySum, txData.InputHash,
+	)
+}
+
+// SenderCreateOutputsFromTx computes the silent payment outputs for a transaction.
+// secretKeys maps the outpoints of the inputs to their secret keys.
+// Every input which is eligible according to BIP352 needs a secret key, the receiver includes it in A_sum.
+// ErrSecretKeyMissing is returned for eligible inputs without a key, keys of other inputs are not used.
+//
+// Signed inputs are categorised from their ScriptSig and witness like the receiver does.
+// Inputs without ScriptSig and witness are categorised from the script of their prevout,
+// assuming they will be signed with the key of that script:
+//   - P2TR inputs are eligible, they are assumed to be spent via the key path
+//   - P2WPKH and P2PKH inputs are eligible unless the secret key belongs to another, e.g. uncompressed, public key
+//   - P2SH inputs are eligible if they are P2SH-P2WPKH of their secret key
+//
+// All inputs are used for the input_hash.
+// The results are stored in the recipients, see SenderCreateOutputs.
+func SenderCreateOutputsFromTx(
+	recipients []*Recipient,
+	tx *wire.MsgTx,
+	fetcher txscript.PrevOutputFetcher,
+	secretKeys map[wire.OutPoint][32]byte,
+	network Network,
+) error {
+	vins, err := VinsFromTx(tx, fetcher)
+	if err != nil {
+		return err
+	}
+
+	eligibleVins, err := ExtractEligibleVins(vins)
+	if err != nil {
+		return err
+	}
+	eligible := make(map[*Vin]bool, len(eligibleVins))
+	for _, vin := range eligibleVins {
+		eligible[vin] = true
+	}
+
+	var vinsSharedDerivation []*Vin
+	for i, txIn := range tx.TxIn {
+		vin := vins[i]
+		secretKey, ok := secretKeys[txIn.PreviousOutPoint]
+
+		if len(vin.ScriptSig) == 0 && len(vin.Witness) == 0 {
+			var secretKeyPtr *[32]byte
+			if ok {
+				secretKeyPtr = &secretKey
+			}
+			eligible[vin] = unsignedInputEligible(vin.ScriptPubKey, secretKeyPtr)
+		}
+
+		if !eligible[vin] {
+			continue
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrSecretKeyMissing, txIn.PreviousOutPoint)
+		}
+		vin.SecretKey = &secretKey
+		vinsSharedDerivation = append(vinsSharedDerivation, vin)
+	}
+
+	return senderCreateOutputs(recipients, vins, vinsSharedDerivation, network)
+}
+
+// unsignedInputEligible reports whether an input spending scriptPubKey is eligible once it is signed with secretKey.
+// secretKey is nil if it is not known, the input is then treated as eligible if its script type can be.
+func unsignedInputEligible(scriptPubKey []byte, secretKey *[32]byte) bool {
+	var pubKeyHash []byte
+	if secretKey != nil {
+		pubKeyHash = Hash160(PubKeyFromSecKey(secretKey)[:])
+	}
+
+	switch {
+	case IsP2TR(scriptPubKey):
+		return true
+	case IsP2WPKH(scriptPubKey):
+		return secretKey == nil || bytes.Equal(pubKeyHash, scriptPubKey[2:])
+	case IsP2PKH(scriptPubKey):
+		return secretKey == nil || bytes.Equal(pubKeyHash, scriptPubKey[3:23])
+	case IsP2SH(scriptPubKey) && secretKey != nil:
+		redeemScript := append([]byte{txscript.OP_0, txscript.OP_DATA_20}, pubKeyHash...)
+		return bytes.Equal(Hash160(redeemScript), scriptPubKey[2:22])
+	default:
+		return false
+	}
+}
@@ -0,0 +1,225 @@
+package bip352
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/setavenger/blindbit-lib/utils"
+	"github.com/stretchr/testify/require"
+)
+
+// txFromTestVins builds a transaction with the given inputs and x-only taproot outputs
+func txFromTestVins(t testing.TB, testVins []VinReceiveTestCase, outputs []string) (*wire.MsgTx, *txscript.MultiPrevOutFetcher) {
+	tx := wire.NewMsgTx(2)
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+
+	for _, vin := range testVins {
+		hash, err := chainhash.NewHashFromStr(vin.Txid)
+		require.NoError(t, err)
+		scriptSig, err := hex.DecodeString(vin.ScriptSig)
+		require.NoError(t, err)
+		scriptPubKey, err := hex.DecodeString(vin.Prevout.ScriptPubKey.Hex)
+		require.NoError(t, err)
+		witness, err := hex.DecodeString(vin.Txinwitness)
+		require.NoError(t, err)
+
+		var witnessScript [][]byte
+		if len(witness) > 0 {
+			witnessScript, err = ParseWitnessScript(witness)
+			require.NoError(t, err)
+		}
+
+		outpoint := wire.NewOutPoint(hash, vin.Vout)
+		txIn := wire.NewTxIn(outpoint, scriptSig, witnessScript)
+		tx.AddTxIn(txIn)
+		fetcher.AddPrevOut(*outpoint, wire.NewTxOut(0, scriptPubKey))
+	}
+
+	for _, output := range outputs {
+		outputBytes, err := hex.DecodeString(output)
+		require.NoError(t, err)
+		tx.AddTxOut(wire.NewTxOut(1000, append([]byte{0x51, 0x20}, outputBytes...)))
+	}
+
+	return tx, fetcher
+}
+
+func TestReceiverScanTx(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	for _, cases := range caseData {
+		for _, testCase := range cases.Receiving {
+			secKeyScan, secKeySpend := testVectorKeys(t, testCase.Given.KeyMaterial.ScanPrivKey, testCase.Given.KeyMaterial.SpendPrivKey)
+			spendPubKey := PubKeyFromSecKey(&secKeySpend)
+
+			var labels []*Label
+			for _, m := range testCase.Given.Labels {
+				label, err := CreateLabel(&secKeyScan, m)
+				require.NoError(t, err)
+				labels = append(labels, &label)
+			}
+
+			tx, fetcher := txFromTestVins(t, testCase.Given.Vin, testCase.Given.Outputs)
+
+			foundOutputs, err := ReceiverScanTx(secKeyScan, spendPubKey, labels, tx, fetcher)
+			if errors.Is(err, ErrNoEligibleVins) {
+				require.Empty(t, testCase.Expected.Outputs, cases.Comment)
+				continue
+			}
+			require.NoError(t, err, cases.Comment)
+			require.Len(t, foundOutputs, len(testCase.Expected.Outputs), cases.Comment)
+
+			for i, foundOutput := range foundOutputs {
+				require.Equal(t, testCase.Expected.Outputs[i].PubKey, hex.EncodeToString(foundOutput.Output[:]), cases.Comment)
+				require.Equal(t, testCase.Expected.Outputs[i].PrivKeyTweak, hex.EncodeToString(foundOutput.SecKeyTweak[:]), cases.Comment)
+			}
+		}
+	}
+}
+
+func TestExtractTxData(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	testCase := caseData[0].Receiving[0]
+	tx, fetcher := txFromTestVins(t, testCase.Given.Vin, testCase.Given.Outputs)
+
+	txData, err := ExtractTxData(tx, fetcher)
+	require.NoError(t, err)
+
+	require.Len(t, txData.Vins, len(testCase.Given.Vin))
+	require.Equal(t, testCase.Given.Vin[0].Txid, hex.EncodeToString(txData.Vins[0].Txid[:]))
+	require.Len(t, txData.Outputs, len(testCase.Given.Outputs))
+
+	publicComponent, inputHash, err := ExtractTweak(testCase.Given.Vin)
+	require.NoError(t, err)
+	require.Equal(t, *publicComponent, *txData.PublicKeySum)
+	require.Equal(t, *inputHash, *txData.InputHash)
+
+	// a missing prevout has to be reported
+	_, err = ExtractTxData(tx, txscript.NewMultiPrevOutFetcher(nil))
+	require.ErrorIs(t, err, ErrPrevOutMissing)
+}
+
+func TestSenderCreateOutputsFromTx(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	for _, cases := range caseData {
+		for _, testCase := range cases.Sending {
+			var testVins []VinReceiveTestCase
+			for _, vin := range testCase.Given.Vin {
+				testVin := VinReceiveTestCase{
+					Txid:        vin.Txid,
+					Vout:        vin.Vout,
+					ScriptSig:   vin.ScriptSig,
+					Txinwitness: vin.Txinwitness,
+				}
+				testVin.Prevout.ScriptPubKey.Hex = vin.Prevout.ScriptPubKey.Hex
+				testVins = append(testVins, testVin)
+			}
+
+			tx, fetcher := txFromTestVins(t, testVins, nil)
+			vins, err := VinsFromTx(tx, fetcher)
+			require.NoError(t, err)
+
+			// only the keys of eligible inputs are handed over
+			secretKeys := make(map[wire.OutPoint][32]byte)
+			for i, vin := range testCase.Given.Vin {
+				if _, utxoType := ExtractPubKey(vins[i]); utxoType == Unknown {
+					continue
+				}
+				secKey, err := hex.DecodeString(vin.PrivateKey)
+				require.NoError(t, err)
+				secretKeys[tx.TxIn[i].PreviousOutPoint] = utils.ConvertToFixedLength32(secKey)
+			}
+
+			var recipients []*Recipient
+			for _, address := range testCase.Given.Recipients {
+				recipients = append(recipients, &Recipient{SilentPaymentAddress: address})
+			}
+
+			err = SenderCreateOutputsFromTx(recipients, tx, fetcher, secretKeys, Mainnet)
+			if errors.Is(err, ErrNoEligibleVins) {
+				require.Empty(t, testCase.Expected.Outputs, cases.Comment)
+				continue
+			}
+			require.NoError(t, err, cases.Comment)
+
+			// the receiver uses every eligible input, leaving out a key must fail instead of creating unspendable outputs
+			for outpoint, secretKey := range secretKeys {
+				delete(secretKeys, outpoint)
+				err = SenderCreateOutputsFromTx(recipients, tx, fetcher, secretKeys, Mainnet)
+				require.ErrorIs(t, err, ErrSecretKeyMissing, cases.Comment)
+				secretKeys[outpoint] = secretKey
+			}
+
+			for _, recipient := range recipients {
+				var found bool
+				for _, output := range testCase.Expected.Outputs {
+					outputBytes, _ := hex.DecodeString(output)
+					if bytes.Equal(outputBytes, recipient.Output[:]) {
+						found = true
+						break
+					}
+				}
+				require.True(t, found, cases.Comment)
+			}
+		}
+	}
+}
+
+func TestSenderCreateOutputsFromUnsignedTx(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	// taproot input with odd y-value and P2PKH input
+	testCase := caseData[9].Sending[0]
+
+	// the inputs are not signed yet
+	var testVins []VinReceiveTestCase
+	secretKeys := make(map[wire.OutPoint][32]byte)
+	for _, vin := range testCase.Given.Vin {
+		testVin := VinReceiveTestCase{Txid: vin.Txid, Vout: vin.Vout}
+		testVin.Prevout.ScriptPubKey.Hex = vin.Prevout.ScriptPubKey.Hex
+		testVins = append(testVins, testVin)
+
+		hash, err := chainhash.NewHashFromStr(vin.Txid)
+		require.NoError(t, err)
+		secKey, err := hex.DecodeString(vin.PrivateKey)
+		require.NoError(t, err)
+		secretKeys[*wire.NewOutPoint(hash, vin.Vout)] = utils.ConvertToFixedLength32(secKey)
+	}
+
+	tx, fetcher := txFromTestVins(t, testVins, nil)
+	recipients := []*Recipient{{SilentPaymentAddress: testCase.Given.Recipients[0]}}
+	require.NoError(t, SenderCreateOutputsFromTx(recipients, tx, fetcher, secretKeys, Mainnet))
+	require.Equal(t, testCase.Expected.Outputs[0], hex.EncodeToString(recipients[0].Output[:]))
+
+	// the eligibility of unsigned inputs follows from the script of the prevout
+	for outpoint, secretKey := range secretKeys {
+		delete(secretKeys, outpoint)
+		err = SenderCreateOutputsFromTx(recipients, tx, fetcher, secretKeys, Mainnet)
+		require.ErrorIs(t, err, ErrSecretKeyMissing)
+		secretKeys[outpoint] = secretKey
+	}
+
+	// the key of an ineligible input is not used, only its outpoint goes into the input_hash
+	p2wsh := VinReceiveTestCase{Txid: hex.EncodeToString(make([]byte, 32)), Vout: 7}
+	p2wsh.Prevout.ScriptPubKey.Hex = "0020" + hex.EncodeToString(make([]byte, 32))
+	tx, fetcher = txFromTestVins(t, append(testVins, p2wsh), nil)
+
+	withoutKey := []*Recipient{{SilentPaymentAddress: testCase.Given.Recipients[0]}}
+	require.NoError(t, SenderCreateOutputsFromTx(withoutKey, tx, fetcher, secretKeys, Mainnet))
+
+	secretKeys[tx.TxIn[len(testVins)].PreviousOutPoint] = [32]byte{0x01}
+	withKey := []*Recipient{{SilentPaymentAddress: testCase.Given.Recipients[0]}}
+	require.NoError(t, SenderCreateOutputsFromTx(withKey, tx, fetcher, secretKeys, Mainnet))
+	require.Equal(t, withoutKey[0].Output, withKey[0].Output)
+}
@@ -0,0 +1,95 @@
+package bip352
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ComputeTweak computes the tweak A_sum·input_hash of a transaction.
+// The tweak can be passed to ReceiverScanTransaction as publicComponent without an inputHash.
+// Returns ErrNoTaprootOutputs, ErrNoEligibleVins or ErrSegwitVersionUnsupported if the transaction can not contain silent payments.
+func ComputeTweak(tx *wire.MsgTx, fetcher txscript.PrevOutputFetcher) (*[33]byte, error) {
+	if isCoinbase(tx) {
+		return nil, ErrNoEligibleVins
+	}
+
+	if len(TaprootOutputs(tx)) == 0 {
+		return nil, ErrNoTaprootOutputs
+	}
+
+	vins, err := VinsFromTx(tx, fetcher)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeySum, err := SumInputPublicKeys(vins)
+	if err != nil {
+		return nil, err
+	}
+
+	inputHash, err := ComputeInputHash(vins, publicKeySum)
+	if err != nil {
+		return nil, err
+	}
+
+	// tweak = input_hash·A_sum
+	return CreateSharedSecret(publicKeySum, inputHash, nil)
+}
+
+// ComputeBlockTweaks computes the tweaks of all transactions in a block that can contain silent payments.
+// Transactions without taproot outputs or eligible inputs, spending segwit versions above 1 and the coinbase are skipped.
+// fetcher has to provide the prevouts of all inputs, outputs created within the block are resolved from the block.
+func ComputeBlockTweaks(block *wire.MsgBlock, fetcher txscript.PrevOutputFetcher) (map[chainhash.Hash][33]byte, error) {
+	blockFetcher := &blockPrevOutFetcher{
+		fetcher: fetcher,
+		outputs: make(map[wire.OutPoint]*wire.TxOut),
+	}
+
+	tweaks := make(map[chainhash.Hash][33]byte)
+	for _, tx := range block.Transactions {
+		txid := tx.TxHash()
+
+		tweak, err := ComputeTweak(tx, blockFetcher)
+		switch {
+		case errors.Is(err, ErrNoTaprootOutputs),
+			errors.Is(err, ErrNoEligibleVins),
+			errors.Is(err, ErrSegwitVersionUnsupported):
+		case err != nil:
+			return nil, fmt.Errorf("tx %s: %w", txid, err)
+		default:
+			tweaks[txid] = *tweak
+		}
+
+		for i, txOut := range tx.TxOut {
+			blockFetcher.outputs[*wire.NewOutPoint(&txid, uint32(i))] = txOut
+		}
+	}
+
+	return tweaks, nil
+}
+
+// blockPrevOutFetcher resolves prevouts from the outputs of previous transactions in a block
+type blockPrevOutFetcher struct {
+	fetcher txscript.PrevOutputFetcher
+	outputs map[wire.OutPoint]*wire.TxOut
+}
+
+func (f *blockPrevOutFetcher) FetchPrevOutput(outpoint wire.OutPoint) *wire.TxOut {
+	if txOut, ok := f.outputs[outpoint]; ok {
+		return txOut
+	}
+	return f.fetcher.FetchPrevOutput(outpoint)
+}
+
+func isCoinbase(tx *wire.MsgTx) bool {
+	if len(tx.TxIn) != 1 {
+		return false
+	}
+	prevOut := tx.TxIn[0].PreviousOutPoint
+	return prevOut.Index == math.MaxUint32 && prevOut.Hash == chainhash.Hash{}
+}
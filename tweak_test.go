@@ -0,0 +1,145 @@
+package bip352
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeTweak(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	for _, cases := range caseData {
+		for _, testCase := range cases.Receiving {
+			tx, fetcher := txFromTestVins(t, testCase.Given.Vin, testCase.Given.Outputs)
+
+			tweak, err := ComputeTweak(tx, fetcher)
+			if len(testCase.Expected.Outputs) == 0 && testCase.Expected.Tweak == "" {
+				require.ErrorIs(t, err, ErrNoEligibleVins, cases.Comment)
+				continue
+			}
+			require.NoError(t, err, cases.Comment)
+			// not all vectors include the tweak
+			if testCase.Expected.Tweak != "" {
+				require.Equal(t, testCase.Expected.Tweak, hex.EncodeToString(tweak[:]), cases.Comment)
+			}
+
+			// the tweak replaces A_sum and the input_hash for scanning
+			secKeyScan, secKeySpend := testVectorKeys(t, testCase.Given.KeyMaterial.ScanPrivKey, testCase.Given.KeyMaterial.SpendPrivKey)
+
+			var labels []*Label
+			for _, m := range testCase.Given.Labels {
+				label, err := CreateLabel(&secKeyScan, m)
+				require.NoError(t, err)
+				labels = append(labels, &label)
+			}
+
+			foundOutputs, err := ReceiverScanTransaction(
+				secKeyScan, PubKeyFromSecKey(&secKeySpend), labels, TaprootOutputs(tx), tweak, nil,
+			)
+			require.NoError(t, err, cases.Comment)
+			require.Len(t, foundOutputs, len(testCase.Expected.Outputs), cases.Comment)
+		}
+	}
+}
+
+func TestComputeTweakIneligible(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+	testCase := caseData[0].Receiving[0]
+
+	// no taproot outputs
+	tx, fetcher := txFromTestVins(t, testCase.Given.Vin, nil)
+	_, err = ComputeTweak(tx, fetcher)
+	require.ErrorIs(t, err, ErrNoTaprootOutputs)
+
+	// spending a segwit v2 output disqualifies the transaction
+	tx, fetcher = txFromTestVins(t, testCase.Given.Vin, testCase.Given.Outputs)
+	outpoint := wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 0}
+	tx.AddTxIn(wire.NewTxIn(&outpoint, nil, nil))
+	fetcher.AddPrevOut(outpoint, wire.NewTxOut(1000, append([]byte{txscript.OP_2, 0x20}, make([]byte, 32)...)))
+	_, err = ComputeTweak(tx, fetcher)
+	require.ErrorIs(t, err, ErrSegwitVersionUnsupported)
+
+	coinbase := wire.NewMsgTx(2)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, math.MaxUint32), []byte{0x01, 0x01}, nil))
+	coinbase.AddTxOut(tx.TxOut[0])
+	_, err = ComputeTweak(coinbase, fetcher)
+	require.ErrorIs(t, err, ErrNoEligibleVins)
+}
+
+func TestComputeBlockTweaks(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	block := wire.NewMsgBlock(&wire.BlockHeader{})
+
+	coinbase := wire.NewMsgTx(2)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, math.MaxUint32), []byte{0x01, 0x01}, nil))
+	coinbase.AddTxOut(wire.NewTxOut(1000, append([]byte{txscript.OP_1, 0x20}, make([]byte, 32)...)))
+	require.NoError(t, block.AddTransaction(coinbase))
+
+	expected := make(map[chainhash.Hash]string)
+	for _, cases := range caseData {
+	testCases:
+		for _, testCase := range cases.Receiving {
+			tx, txFetcher := txFromTestVins(t, testCase.Given.Vin, testCase.Given.Outputs)
+
+			// some vectors reuse outpoints with different prevouts, a block can only spend them once
+			for _, txIn := range tx.TxIn {
+				if fetcher.FetchPrevOutput(txIn.PreviousOutPoint) != nil {
+					continue testCases
+				}
+			}
+
+			fetcher.Merge(txFetcher)
+			require.NoError(t, block.AddTransaction(tx))
+
+			if testCase.Expected.Tweak != "" {
+				expected[tx.TxHash()] = testCase.Expected.Tweak
+			}
+		}
+	}
+	require.NotEmpty(t, expected)
+
+	// a transaction spending an output created earlier in the block
+	tweakedTx := block.Transactions[1]
+	spendingTx := wire.NewMsgTx(2)
+	tweakedTxid := tweakedTx.TxHash()
+	// key-path spend of the first output, the signature is not validated
+	spendingTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&tweakedTxid, 0), nil, wire.TxWitness{make([]byte, 64)}))
+	spendingTx.AddTxOut(tweakedTx.TxOut[0])
+	require.NoError(t, block.AddTransaction(spendingTx))
+
+	tweaks, err := ComputeBlockTweaks(block, fetcher)
+	require.NoError(t, err)
+
+	spendingTweak, ok := tweaks[spendingTx.TxHash()]
+	require.True(t, ok)
+	delete(tweaks, spendingTx.TxHash())
+
+	for txid, tweakHex := range expected {
+		tweak, ok := tweaks[txid]
+		require.True(t, ok)
+		require.Equal(t, tweakHex, hex.EncodeToString(tweak[:]))
+	}
+	_, ok = tweaks[coinbase.TxHash()]
+	require.False(t, ok)
+
+	blockFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	blockFetcher.AddPrevOut(*wire.NewOutPoint(&tweakedTxid, 0), tweakedTx.TxOut[0])
+	tweak, err := ComputeTweak(spendingTx, blockFetcher)
+	require.NoError(t, err)
+	require.Equal(t, *tweak, spendingTweak)
+
+	// missing prevouts are reported
+	_, err = ComputeBlockTweaks(block, txscript.NewMultiPrevOutFetcher(nil))
+	require.ErrorIs(t, err, ErrPrevOutMissing)
+}
@@ -5,11 +5,12 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math/big"
 	"sort"
 
 	"github.com/btcsuite/btcd/btcec/v2"
-	golibsecp256k1 "github.com/setavenger/go-libsecp256k1"
+	"github.com/btcsuite/btcd/wire"
 )
 
 var (
@@ -60,7 +61,7 @@ func RecursiveAddPrivateKeys(secretKeys [][32]byte) [32]byte {
 			secretKeysSum = secretKeys[0]
 			continue
 		}
-		golibsecp256k1.SecKeyAdd(&secretKeysSum, &secretKeys[i])
+		secKeyAdd(&secretKeysSum, &secretKeys[i])
 	}
 
 	return secretKeysSum
@@ -114,36 +115,44 @@ func FindSmallestOutpoint(vins []*Vin) ([]byte, error) {
 	return outpoints[0], nil
 }
 
-// ParseWitnessScript parses a hex-encoded witness script and returns the actual witness data as a list
+// ParseWitnessScript parses a serialized witness and returns the witness items as a list.
+// The item count and the item lengths are CompactSize encoded as in the serialization of a transaction.
+// Returns ErrInvalidWitness if the data is truncated, not canonically encoded or has trailing bytes.
 func ParseWitnessScript(data []byte) ([][]byte, error) {
+	r := bytes.NewReader(data)
 
-	// The first byte indicates the number of items in the witness data
-	itemCount := int(data[0])
-	var witnessData [][]byte
-	i := 1 // Start index after the item count byte
+	itemCount, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: item count: %v", ErrInvalidWitness, err)
+	}
 
-	for j := 0; j < itemCount && i < len(data); j++ {
-		if i >= len(data) {
-			return nil, fmt.Errorf("script is shorter than expected")
-		}
+	// every item needs at least one byte for its length, this also bounds the allocation
+	if itemCount > uint64(r.Len()) {
+		return nil, fmt.Errorf("%w: script is shorter than expected", ErrInvalidWitness)
+	}
 
-		// The first byte of each item indicates its length
-		length := int(data[i])
-		i++
+	witnessData := make([][]byte, 0, itemCount)
+	for j := uint64(0); j < itemCount; j++ {
+		length, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%w: item length: %v", ErrInvalidWitness, err)
+		}
 
-		// Extract the witness data item based on the length
-		if i+length > len(data) {
-			return nil, fmt.Errorf("invalid length for witness data item")
+		if length > uint64(r.Len()) {
+			return nil, fmt.Errorf("%w: invalid length for witness data item", ErrInvalidWitness)
 		}
-		item := data[i : i+length]
 
-		// Append the hex-encoded item to the result list
-		witnessData = append(witnessData, item)
-		i += length
+		start := len(data) - r.Len()
+		witnessData = append(witnessData, data[start:start+int(length)])
+
+		_, err = r.Seek(int64(length), io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if len(witnessData) != itemCount {
-		return nil, fmt.Errorf("actual item count does not match the expected count")
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%w: script is longer than expected", ErrInvalidWitness)
 	}
 
 	return witnessData, nil
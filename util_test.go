@@ -6,7 +6,9 @@ import (
 	"testing"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/setavenger/blindbit-lib/utils"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRecursiveAddPrivateKeys(t *testing.T) {
@@ -58,3 +60,79 @@ func testRecursiveHelper(t *testing.T, secKeys [][32]byte, pKeys [][33]byte) {
 		return
 	}
 }
+
+func TestParseWitnessScript(t *testing.T) {
+	// items longer than 252 bytes use a 3 byte CompactSize length
+	item := bytes.Repeat([]byte{0xab}, 300)
+	data := append([]byte{0x02, 0x01, 0x01, 0xfd, 0x2c, 0x01}, item...)
+
+	witness, err := ParseWitnessScript(data)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{{0x01}, item}, witness)
+
+	for _, invalid := range [][]byte{
+		nil,
+		{0x01},
+		{0x02, 0x01, 0x01},
+		{0x01, 0x02, 0x01},
+		// trailing data
+		{0x01, 0x01, 0x01, 0x00},
+		// non-canonical CompactSize
+		{0x01, 0xfd, 0x01, 0x00, 0x01},
+		// item count larger than the data
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	} {
+		_, err = ParseWitnessScript(invalid)
+		require.ErrorIs(t, err, ErrInvalidWitness, hex.EncodeToString(invalid))
+	}
+
+	witness, err = ParseWitnessScript([]byte{0x00})
+	require.NoError(t, err)
+	require.Empty(t, witness)
+}
+
+func TestParseWitnessScriptStrict(t *testing.T) {
+	// witnesses the former lenient parser accepted, the valid prefix is not returned anymore
+	for _, testCase := range []struct {
+		name string
+		data []byte
+	}{
+		{"trailing bytes", []byte{0x01, 0x01, 0xaa, 0xbb}},
+		{"fewer items than the count", []byte{0x02, 0x01, 0xaa}},
+		{"non-canonical item count", []byte{0xfd, 0x01, 0x00, 0x01, 0xaa}},
+		{"non-canonical item length", []byte{0x01, 0xfd, 0x01, 0x00, 0xaa}},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			witness, err := ParseWitnessScript(testCase.data)
+			require.ErrorIs(t, err, ErrInvalidWitness)
+			require.Nil(t, witness)
+		})
+	}
+
+	// the same witnesses without the malformed parts
+	witness, err := ParseWitnessScript([]byte{0x01, 0x01, 0xaa})
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{{0xaa}}, witness)
+}
+
+func FuzzParseWitnessScript(f *testing.F) {
+	for _, vin := range receivingTestVins(f) {
+		witness, _ := hex.DecodeString(vin.Txinwitness)
+		f.Add(witness)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		witness, err := ParseWitnessScript(data)
+		if err != nil {
+			return
+		}
+
+		// a successfully parsed witness serializes to the same bytes
+		var buf bytes.Buffer
+		require.NoError(t, wire.WriteVarInt(&buf, 0, uint64(len(witness))))
+		for _, item := range witness {
+			require.NoError(t, wire.WriteVarBytes(&buf, 0, item))
+		}
+		require.Equal(t, data, buf.Bytes())
+	})
+}
@@ -6,15 +6,16 @@ import (
 )
 
 type Vin struct {
-	Txid         [32]byte  // txid has to be in the normal human-readable format
-	Vout         uint32    // output index of the input
-	Amount       uint64    // value of the utxo in satoshi (100_000_000 sat = 1 Bitcoin)
-	PublicKey    *[33]byte // 33 byte compressed public key or 32 byte taproot x-only key
-	SecretKey    *[32]byte // 32 byte secret key
-	Taproot      bool      // indicates whether input is taproot or not. taproot outputs have to be even hence the flag has to be set, so we can check for negation
-	Witness      [][]byte  // witness data for the input
-	ScriptPubKey []byte    // the scriptPubKey of the input
-	ScriptSig    []byte    // used for p2pkh
+	Txid         [32]byte   // txid has to be in the normal human-readable format
+	Vout         uint32     // output index of the input
+	Amount       uint64     // value of the utxo in satoshi (100_000_000 sat = 1 Bitcoin)
+	PublicKey    *[33]byte  // 33 byte compressed public key or 32 byte taproot x-only key
+	SecretKey    *[32]byte  // 32 byte secret key
+	Signer       ECDHSigner // used instead of SecretKey if the secret key is not available in memory
+	Taproot      bool       // indicates whether input is taproot or not. taproot outputs have to be even hence the flag has to be set, so we can check for negation
+	Witness      [][]byte   // witness data for the input
+	ScriptPubKey []byte     // the scriptPubKey of the input
+	ScriptSig    []byte     // used for p2pkh
 }
 
 func (v Vin) Hash() *chainhash.Hash {
@@ -58,6 +59,7 @@ func (v *Vin) DeepCopy() *Vin {
 		Vout:    v.Vout,
 		Amount:  v.Amount,
 		Taproot: v.Taproot,
+		Signer:  v.Signer, // the signer is shared, it holds no per vin state
 	}
 
 	// Copy PublicKey
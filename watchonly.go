@@ -0,0 +1,270 @@
+package bip352
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/setavenger/blindbit-lib/utils"
+)
+
+// WatchOnlyKey holds everything needed to find the outputs of a receiver: the scan secret key b_scan and
+// the spend public key B_spend. The spend secret key is never needed, a server scanning with a
+// WatchOnlyKey can not spend the outputs it finds.
+type WatchOnlyKey struct {
+	scanSecKey  [32]byte
+	spendPubKey [33]byte
+	birthday    uint32
+	network     Network
+}
+
+type watchOnlyKeyJSON struct {
+	ScanSecKey  string  `json:"scan_sec_key"`
+	SpendPubKey string  `json:"spend_pub_key"`
+	Birthday    uint32  `json:"birthday"`
+	Network     Network `json:"network"`
+}
+
+// NewWatchOnlyKey creates a WatchOnlyKey.
+// birthday: height of the first block that can contain outputs of the receiver, earlier blocks don't need to be scanned
+func NewWatchOnlyKey(scanSecKey [32]byte, spendPubKey [33]byte, birthday uint32, network Network) (*WatchOnlyKey, error) {
+	if _, err := NewPrivateKeySigner(scanSecKey); err != nil {
+		return nil, err
+	}
+	if err := validatePublicKey(spendPubKey); err != nil {
+		return nil, err
+	}
+
+	return &WatchOnlyKey{
+		scanSecKey:  scanSecKey,
+		spendPubKey: spendPubKey,
+		birthday:    birthday,
+		network:     network,
+	}, nil
+}
+
+func (w *WatchOnlyKey) ScanSecKey() [32]byte {
+	return w.scanSecKey
+}
+
+func (w *WatchOnlyKey) SpendPubKey() [33]byte {
+	return w.spendPubKey
+}
+
+func (w *WatchOnlyKey) Birthday() uint32 {
+	return w.birthday
+}
+
+func (w *WatchOnlyKey) Network() Network {
+	return w.network
+}
+
+// Address returns the v0 silent payment address of the receiver
+func (w *WatchOnlyKey) Address() (SilentPaymentAddress, error) {
+	return NewSilentPaymentAddress(*PubKeyFromSecKey(&w.scanSecKey), w.spendPubKey, w.network, 0)
+}
+
+// LabelManager creates a LabelManager for the receiver, labels only depend on the scan secret key
+func (w *WatchOnlyKey) LabelManager() (*LabelManager, error) {
+	return NewLabelManager(w.scanSecKey, w.spendPubKey, w.network)
+}
+
+// Descriptor returns the watch-only descriptor of the receiver, the birthday is not part of it.
+// The text form of descriptors of the test networks does not record the network, see Descriptor.MarshalText.
+func (w *WatchOnlyKey) Descriptor() (Descriptor, error) {
+	return NewWatchOnlyDescriptor(w.scanSecKey, w.spendPubKey, w.network)
+}
+
+func (w *WatchOnlyKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(watchOnlyKeyJSON{
+		ScanSecKey:  hex.EncodeToString(w.scanSecKey[:]),
+		SpendPubKey: hex.EncodeToString(w.spendPubKey[:]),
+		Birthday:    w.birthday,
+		Network:     w.network,
+	})
+}
+
+func (w *WatchOnlyKey) UnmarshalJSON(data []byte) error {
+	var alias watchOnlyKeyJSON
+	err := json.Unmarshal(data, &alias)
+	if err != nil {
+		return err
+	}
+
+	scanSecKey, err := hex.DecodeString(alias.ScanSecKey)
+	if err != nil {
+		return err
+	}
+	if len(scanSecKey) != 32 {
+		return ErrInvalidSecretKey
+	}
+
+	spendPubKey, err := hex.DecodeString(alias.SpendPubKey)
+	if err != nil {
+		return err
+	}
+	if len(spendPubKey) != 33 {
+		return ErrInvalidPublicKey
+	}
+
+	key, err := NewWatchOnlyKey(
+		utils.ConvertToFixedLength32(scanSecKey),
+		utils.ConvertToFixedLength33(spendPubKey),
+		alias.Birthday,
+		alias.Network,
+	)
+	if err != nil {
+		return err
+	}
+
+	*w = *key
+	return nil
+}
+
+// ScanTx scans a transaction for outputs of the receiver.
+// labelSet can be nil if no labels should be checked.
+func (w *WatchOnlyKey) ScanTx(
+	tx *wire.MsgTx,
+	fetcher txscript.PrevOutputFetcher,
+	labelSet *LabelSet,
+) ([]*WatchOnlyOutput, error) {
+	txData, err := ExtractTxData(tx, fetcher)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedSecret, err := CreateSharedSecret(txData.PublicKeySum, &w.scanSecKey, txData.InputHash)
+	if err != nil {
+		return nil, err
+	}
+
+	watchOnlyOutputs, err := w.scan(sharedSecret, txData.Outputs, labelSet)
+	if err != nil {
+		return nil, err
+	}
+
+	// locate the outputs in the transaction
+	txid := tx.TxHash()
+	for _, watchOnlyOutput := range watchOnlyOutputs {
+		for vout, txOut := range tx.TxOut {
+			if IsP2TR(txOut.PkScript) && [32]byte(txOut.PkScript[2:]) == watchOnlyOutput.Output {
+				watchOnlyOutput.OutPoint = *wire.NewOutPoint(&txid, uint32(vout))
+				watchOnlyOutput.Amount = uint64(txOut.Value)
+				break
+			}
+		}
+	}
+
+	return watchOnlyOutputs, nil
+}
+
+// ScanTweak scans the taproot outputs of a transaction with its tweak A_sum·input_hash.
+// This is the entry point for light clients receiving tweaks from an index server.
+// OutPoint and Amount of the returned outputs are not set.
+func (w *WatchOnlyKey) ScanTweak(tweak [33]byte, outputs [][32]byte, labelSet *LabelSet) ([]*WatchOnlyOutput, error) {
+	sharedSecret, err := CreateSharedSecret(&tweak, &w.scanSecKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.scan(sharedSecret, outputs, labelSet)
+}
+
+func (w *WatchOnlyKey) scan(sharedSecret *[33]byte, outputs [][32]byte, labelSet *LabelSet) ([]*WatchOnlyOutput, error) {
+	spendPubKey := w.spendPubKey
+	foundOutputs, err := ReceiverScanTransactionWithLabelSet(&spendPubKey, labelSet, outputs, sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	watchOnlyOutputs := make([]*WatchOnlyOutput, len(foundOutputs))
+	for i, foundOutput := range foundOutputs {
+		watchOnlyOutputs[i] = &WatchOnlyOutput{
+			Output:      foundOutput.Output,
+			SecKeyTweak: foundOutput.SecKeyTweak,
+		}
+		if foundOutput.Label != nil {
+			m := foundOutput.Label.M
+			watchOnlyOutputs[i].LabelM = &m
+		}
+	}
+
+	return watchOnlyOutputs, nil
+}
+
+// WatchOnlyOutput is an output found with a WatchOnlyKey.
+// It contains no secret key material and can be stored by the scanning server.
+// The output can only be spent with the spend secret key, see FoundOutput.
+type WatchOnlyOutput struct {
+	OutPoint    wire.OutPoint
+	Amount      uint64   // value in satoshi
+	Output      [32]byte // x-only pubKey
+	SecKeyTweak [32]byte // tweak to add to the spend secret key, includes the label tweak
+	LabelM      *uint32  // m of the matched label, nil for outputs without label
+}
+
+type watchOnlyOutputJSON struct {
+	OutPoint    string  `json:"outpoint"`
+	Amount      uint64  `json:"amount"`
+	Output      string  `json:"output"`
+	SecKeyTweak string  `json:"tweak"`
+	LabelM      *uint32 `json:"label,omitempty"`
+}
+
+// FoundOutput converts the output for spending, see FoundOutput.PrivateKey and FoundOutput.SignKeyPath.
+// The label of the FoundOutput is not set, the tweak already includes it.
+func (o *WatchOnlyOutput) FoundOutput() *FoundOutput {
+	return &FoundOutput{
+		Output:      o.Output,
+		SecKeyTweak: o.SecKeyTweak,
+	}
+}
+
+func (o *WatchOnlyOutput) MarshalJSON() ([]byte, error) {
+	return json.Marshal(watchOnlyOutputJSON{
+		OutPoint:    o.OutPoint.String(),
+		Amount:      o.Amount,
+		Output:      hex.EncodeToString(o.Output[:]),
+		SecKeyTweak: hex.EncodeToString(o.SecKeyTweak[:]),
+		LabelM:      o.LabelM,
+	})
+}
+
+func (o *WatchOnlyOutput) UnmarshalJSON(data []byte) error {
+	var alias watchOnlyOutputJSON
+	err := json.Unmarshal(data, &alias)
+	if err != nil {
+		return err
+	}
+
+	outPoint, err := wire.NewOutPointFromString(alias.OutPoint)
+	if err != nil {
+		return err
+	}
+
+	output, err := hex.DecodeString(alias.Output)
+	if err != nil {
+		return err
+	}
+	if len(output) != 32 {
+		return ErrInvalidPublicKey
+	}
+
+	tweak, err := hex.DecodeString(alias.SecKeyTweak)
+	if err != nil {
+		return err
+	}
+	if len(tweak) != 32 {
+		return ErrInvalidSecretKey
+	}
+
+	*o = WatchOnlyOutput{
+		OutPoint:    *outPoint,
+		Amount:      alias.Amount,
+		Output:      utils.ConvertToFixedLength32(output),
+		SecKeyTweak: utils.ConvertToFixedLength32(tweak),
+		LabelM:      alias.LabelM,
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package bip352
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchOnlyKeyScanTx(t *testing.T) {
+	caseData, err := LoadFullCaseData(t)
+	require.NoError(t, err)
+
+	for _, cases := range caseData {
+		for _, testCase := range cases.Receiving {
+			secKeyScan, secKeySpend := testVectorKeys(t, testCase.Given.KeyMaterial.ScanPrivKey, testCase.Given.KeyMaterial.SpendPrivKey)
+
+			key, err := NewWatchOnlyKey(secKeyScan, *PubKeyFromSecKey(&secKeySpend), 840_000, Mainnet)
+			require.NoError(t, err)
+
+			labelSet := NewLabelSet()
+			for _, m := range testCase.Given.Labels {
+				label, err := CreateLabel(&secKeyScan, m)
+				require.NoError(t, err)
+				labelSet.Add(&label)
+			}
+
+			tx, fetcher := txFromTestVins(t, testCase.Given.Vin, testCase.Given.Outputs)
+			outputs, err := key.ScanTx(tx, fetcher, labelSet)
+			if err != nil {
+				require.ErrorIs(t, err, ErrNoEligibleVins)
+				continue
+			}
+			require.Len(t, outputs, len(testCase.Expected.Outputs), cases.Comment)
+
+			txid := tx.TxHash()
+			for i, output := range outputs {
+				require.Equal(t, testCase.Expected.Outputs[i].PubKey, hex.EncodeToString(output.Output[:]), cases.Comment)
+				require.Equal(t, testCase.Expected.Outputs[i].PrivKeyTweak, hex.EncodeToString(output.SecKeyTweak[:]), cases.Comment)
+				require.Equal(t, txid, output.OutPoint.Hash)
+				require.Equal(t, output.Output[:], tx.TxOut[output.OutPoint.Index].PkScript[2:])
+
+				// the spending device only needs the record and the spend secret key
+				privateKey, err := output.FoundOutput().PrivateKey(secKeySpend)
+				require.NoError(t, err)
+				require.Equal(t, output.Output[:], PubKeyFromSecKey(&privateKey)[1:])
+			}
+
+			// scanning with the tweak finds the same outputs
+			tweak, err := ComputeTweak(tx, fetcher)
+			require.NoError(t, err)
+			tweakOutputs, err := key.ScanTweak(*tweak, TaprootOutputs(tx), labelSet)
+			require.NoError(t, err)
+			require.Len(t, tweakOutputs, len(outputs))
+			for i := range outputs {
+				require.Equal(t, outputs[i].Output, tweakOutputs[i].Output)
+				require.Equal(t, outputs[i].LabelM, tweakOutputs[i].LabelM)
+			}
+		}
+	}
+}
+
+func TestWatchOnlyKeyJSON(t *testing.T) {
+	scanSecKey, spendSecKey := testKeys()
+
+	key, err := NewWatchOnlyKey(scanSecKey, *PubKeyFromSecKey(&spendSecKey), 100, Signet)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(key)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), hex.EncodeToString(spendSecKey[:]))
+
+	var decoded WatchOnlyKey
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, *key, decoded)
+	require.Equal(t, Signet, decoded.Network())
+	require.Equal(t, uint32(100), decoded.Birthday())
+
+	address, err := decoded.Address()
+	require.NoError(t, err)
+	expected, err := CreateAddress(PubKeyFromSecKey(&scanSecKey), PubKeyFromSecKey(&spendSecKey), Signet, 0)
+	require.NoError(t, err)
+	require.Equal(t, expected, address.String())
+
+	labelManager, err := decoded.LabelManager()
+	require.NoError(t, err)
+	changeLabel, err := labelManager.ChangeLabel()
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), changeLabel.M)
+
+	require.Error(t, json.Unmarshal([]byte(`{"scan_sec_key":"00","spend_pub_key":"02","network":"mainnet"}`), &decoded))
+	require.ErrorIs(t, json.Unmarshal([]byte(`{"network":"mars"}`), &decoded), ErrUnknownNetwork)
+
+	_, err = NewWatchOnlyKey([32]byte{}, *PubKeyFromSecKey(&spendSecKey), 0, Mainnet)
+	require.ErrorIs(t, err, ErrInvalidSecretKey)
+	_, err = NewWatchOnlyKey(scanSecKey, [33]byte{0x02}, 0, Mainnet)
+	require.ErrorIs(t, err, ErrInvalidPublicKey)
+}
+
+func TestWatchOnlyOutputJSON(t *testing.T) {
+	m := uint32(3)
+	output := &WatchOnlyOutput{
+		Amount:      1000,
+		Output:      sha256.Sum256([]byte("output")),
+		SecKeyTweak: sha256.Sum256([]byte("tweak")),
+		LabelM:      &m,
+	}
+	output.OutPoint.Hash[0] = 0x01
+	output.OutPoint.Index = 2
+
+	data, err := json.Marshal(output)
+	require.NoError(t, err)
+
+	var decoded WatchOnlyOutput
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, *output, decoded)
+}